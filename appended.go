@@ -2,108 +2,232 @@ package rice
 
 import (
 	"archive/zip"
-	"log"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/archive"
+	"github.com/GeertJohan/go.rice/internal/encryption"
 	"github.com/daaku/go.zipexe"
 )
 
 // appendedBox defines an appended box
 type appendedBox struct {
-	Name  string                   // box name
-	Files map[string]*appendedFile // appended files (*zip.File) by full path
-	Time  time.Time
+	Name    string                   // box name
+	Package string                   // import path of the package that uses the box, empty for archives created by older versions of rice
+	Files   map[string]*appendedFile // appended files (*zip.File) by full path
+	Time    time.Time
+	archive *appendedArchive // the archive the box was loaded from
+}
+
+var (
+	// ErrNotSigned is returned by FindBox when Config.AppendedPublicKey is
+	// set and the archive holding an appended box is not signed.
+	ErrNotSigned = archive.ErrNotSigned
+
+	// ErrInvalidSignature is returned by FindBox when Config.AppendedPublicKey
+	// is set and the archive holding an appended box is not signed with the
+	// matching private key, or was modified after signing.
+	ErrInvalidSignature = archive.ErrInvalidSignature
+)
+
+// appendedArchive is a zip archive appended to the executable
+type appendedArchive struct {
+	ra      io.ReaderAt // the executable, nil when the archive was not appended by rice
+	size    int64       // size of the executable
+	comment string      // comment of the archive, holds the signature
+
+	mu       sync.Mutex
+	verified map[string]error // result of verify by public key
+}
+
+// verify checks the signature of the archive against the given public key.
+// The archive is only read for the first verification with a key.
+func (a *appendedArchive) verify(key ed25519.PublicKey) error {
+	if a == nil || a.ra == nil {
+		return archive.ErrNotSigned
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err, ok := a.verified[string(key)]; ok {
+		return err
+	}
+	err := archive.Verify(a.ra, a.size, a.comment, key)
+	if a.verified == nil {
+		a.verified = make(map[string]error)
+	}
+	a.verified[string(key)] = err
+	return err
 }
 
 type appendedFile struct {
-	zipFile  *zip.File
-	dir      bool
-	dirInfo  *appendedDirInfo
-	children []*appendedFile
-	content  []byte
+	zipFile   *zip.File
+	archive   io.ReaderAt // data the offsets in zipFile refer to, nil when unknown
+	dir       bool
+	encrypted bool
+	hash      string // recorded SHA-256 of the content, see Box.Hash
+	dirInfo   *appendedDirInfo
+	children  []*appendedFile
 }
 
-// appendedBoxes is a public register of appendes boxes
+// appendedBoxes is a public register of appended boxes, keyed by embedded.BoxKey
 var appendedBoxes = make(map[string]*appendedBox)
 
+// appendedLoadErrors holds the problems found while loading the appended boxes
+var appendedLoadErrors []error
+
+// AppendedLoadErrors returns the errors that occurred while looking for and
+// loading the boxes appended to the executable. Files that couldn't be loaded
+// are left out of their box. An executable without appended boxes is not an
+// error.
+func AppendedLoadErrors() []error {
+	return append([]error(nil), appendedLoadErrors...)
+}
+
 func init() {
+	// appended boxes can be disabled for programs that must never parse trailing data in their executable
+	if appendedDisabled || os.Getenv("RICE_NOAPPEND") != "" {
+		return
+	}
+
 	// find if exec is appended
 	thisFile, err := os.Executable()
 	if err != nil {
-		return // not appended or cant find self executable
+		appendedLoadErrors = append(appendedLoadErrors, err)
+		return
+	}
+	boxes, errs := openAppendedBoxes(thisFile)
+	if boxes != nil {
+		appendedBoxes = boxes
+	}
+	appendedLoadErrors = errs
+}
+
+// openAppendedBoxes loads the boxes appended to the given executable,
+// it returns nil boxes when nothing is appended.
+func openAppendedBoxes(exePath string) (map[string]*appendedBox, []error) {
+	exePath, err := filepath.EvalSymlinks(exePath)
+	if err != nil {
+		return nil, []error{err}
 	}
-	thisFile, err = filepath.EvalSymlinks(thisFile)
+	// the executable stays open (or mapped) for the lifetime of the process,
+	// file contents are only read when a file is opened
+	exe, size, err := openExecutable(exePath)
 	if err != nil {
-		return
+		return nil, []error{err}
+	}
+	// rice append writes the archive with offsets relative to the start of
+	// the executable, so zip.NewReader finds it and data offsets point into exe
+	rd, err := zip.NewReader(exe, size)
+	if err == nil {
+		return loadAppendedBoxes(exe, size, rd)
 	}
-	closer, rd, err := zipexe.OpenCloser(thisFile)
+	if err != zip.ErrFormat {
+		// there is a zip archive, but it is broken
+		closeExecutable(exe)
+		return nil, []error{&os.PathError{Op: "load", Path: exePath, Err: err}}
+	}
+	// the archive was added some other way (e.g. zip -A or as a section),
+	// offsets are relative to where zipexe found it
+	rd, err = zipexe.NewReader(exe, size)
 	if err != nil {
-		return // not appended
+		closeExecutable(exe)
+		return nil, nil // not appended
+	}
+	return loadAppendedBoxes(nil, 0, rd)
+}
+
+// closeExecutable closes an executable opened by openExecutable
+func closeExecutable(exe io.ReaderAt) {
+	if closer, ok := exe.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// loadAppendedBoxes reads the boxes from the zip data appended to an executable.
+// Only the zip directory is read, file contents are read when a file is opened.
+// When ra is the data (of the given size) the offsets in rd refer to, stored
+// files are read from it directly and the signature of the archive can be
+// verified. Otherwise (ra is nil) files are read through rd.
+// Files that can't be loaded are skipped, an error is returned for each of them.
+func loadAppendedBoxes(ra io.ReaderAt, size int64, rd *zip.Reader) (map[string]*appendedBox, []error) {
+	boxes := make(map[string]*appendedBox)
+	var errs []error
+	arc := &appendedArchive{
+		ra:      ra,
+		size:    size,
+		comment: rd.Comment,
 	}
-	defer closer.Close()
+	namespaced := archive.IsNamespaced(rd.Comment)
 
 	for _, f := range rd.File {
 		// get box and file name from f.Name
-		fileParts := strings.SplitN(strings.TrimLeft(filepath.ToSlash(f.Name), "/"), "/", 2)
-		boxName := fileParts[0]
-		var fileName string
-		if len(fileParts) > 1 {
-			fileName = fileParts[1]
+		var namespace, boxName, fileName string
+		if namespaced {
+			var err error
+			namespace, boxName, fileName, err = archive.SplitName(filepath.ToSlash(f.Name))
+			if err != nil {
+				errs = append(errs, &os.PathError{Op: "load", Path: f.Name, Err: err})
+				continue
+			}
+		} else {
+			// archives by older versions of rice use the box name, with
+			// slashes replaced by dashes, as top level directory
+			fileParts := strings.SplitN(strings.TrimLeft(filepath.ToSlash(f.Name), "/"), "/", 2)
+			boxName = fileParts[0]
+			if len(fileParts) > 1 {
+				fileName = fileParts[1]
+			}
+		}
+
+		if f.Method == zip.Store && f.CompressedSize64 != f.UncompressedSize64 {
+			errs = append(errs, &os.PathError{Op: "load", Path: f.Name, Err: errors.New("size of stored file doesn't match its data")})
+			continue
 		}
 
 		// find box or create new one if doesn't exist
-		box := appendedBoxes[boxName]
+		boxKey := embedded.BoxKey(namespace, boxName)
+		box := boxes[boxKey]
 		if box == nil {
 			box = &appendedBox{
-				Name:  boxName,
-				Files: make(map[string]*appendedFile),
-				Time:  f.ModTime(),
+				Name:    boxName,
+				Package: namespace,
+				Files:   make(map[string]*appendedFile),
+				Time:    f.ModTime(),
+				archive: arc,
 			}
-			appendedBoxes[boxName] = box
+			boxes[boxKey] = box
 		}
 
 		// create and add file to box
 		af := &appendedFile{
 			zipFile: f,
+			archive: ra,
 		}
-		if f.Comment == "dir" {
+		af.encrypted = archive.HasAttr(f.Comment, archive.EncryptedAttr)
+		af.hash = archive.AttrValue(f.Comment, archive.HashAttr)
+		if archive.HasAttr(f.Comment, archive.DirAttr) {
 			af.dir = true
 			af.dirInfo = &appendedDirInfo{
-				name: filepath.Base(af.zipFile.Name),
+				name: path.Base(path.Join(boxName, fileName)),
 				time: af.zipFile.ModTime(),
 			}
-		} else {
-			// this is a file, we need it's contents so we can create a bytes.Reader when the file is opened
-			// make a new byteslice
-			af.content = make([]byte, af.zipFile.FileInfo().Size())
-			// ignore reading empty files from zip (empty file still is a valid file to be read though!)
-			if len(af.content) > 0 {
-				// open io.ReadCloser
-				rc, err := af.zipFile.Open()
-				if err != nil {
-					af.content = nil // this will cause an error when the file is being opened or seeked (which is good)
-					// TODO: it's quite blunt to just log this stuff. but this is in init, so rice.Debug can't be changed yet..
-					log.Printf("error opening appended file %s: %v", af.zipFile.Name, err)
-				} else {
-					_, err = rc.Read(af.content)
-					rc.Close()
-					if err != nil {
-						af.content = nil // this will cause an error when the file is being opened or seeked (which is good)
-						// TODO: it's quite blunt to just log this stuff. but this is in init, so rice.Debug can't be changed yet..
-						log.Printf("error reading data for appended file %s: %v", af.zipFile.Name, err)
-					}
-				}
-			}
 		}
 
 		// add appendedFile to box file list
 		box.Files[fileName] = af
 
 		// add to parent dir (if any)
-		dirName := filepath.Dir(fileName)
+		dirName := path.Dir(fileName)
 		if dirName == "." {
 			dirName = ""
 		}
@@ -113,6 +237,135 @@ func init() {
 			}
 		}
 	}
+
+	// sort the directory listings, so Readdir returns them in lexical order
+	for _, box := range boxes {
+		for _, af := range box.Files {
+			sort.Slice(af.children, func(i, j int) bool {
+				return af.children[i].info().Name() < af.children[j].info().Name()
+			})
+		}
+	}
+
+	return boxes, errs
+}
+
+// info returns the os.FileInfo for the appended file
+func (af *appendedFile) info() os.FileInfo {
+	if af.dir {
+		return af.dirInfo
+	}
+	if af.encrypted {
+		return encryptedFileInfo{af.zipFile.FileInfo()}
+	}
+	return af.zipFile.FileInfo()
+}
+
+// encryptedFileInfo is the os.FileInfo of an encrypted file, with the size
+// of the decrypted content
+type encryptedFileInfo struct {
+	os.FileInfo
+}
+
+// Size returns the size of the decrypted file
+// (implementing os.FileInfo)
+func (fi encryptedFileInfo) Size() int64 {
+	return fi.FileInfo.Size() - encryption.Overhead
+}
+
+// readAll reads the whole (decompressed) content of the appended file
+func (af *appendedFile) readAll() ([]byte, error) {
+	rc, err := af.zipFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	content := make([]byte, af.zipFile.UncompressedSize64)
+	if _, err := io.ReadFull(rc, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// newAppendedVirtualFile creates a virtualFile to read the given appended file.
+// Stored (uncompressed) files are read directly from the executable,
+// other files are decompressed while they are being read.
+func newAppendedVirtualFile(name string, af *appendedFile) (*virtualFile, error) {
+	size := int64(af.zipFile.UncompressedSize64)
+	var content io.ReaderAt = &zipStreamReader{file: af.zipFile}
+	if af.zipFile.Method == zip.Store && af.archive != nil {
+		offset, err := af.zipFile.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		content = io.NewSectionReader(af.archive, offset, size)
+	}
+	return newVirtualFile(name, af.info(), content, size), nil
+}
+
+// zipStreamReader implements io.ReaderAt for a compressed file in a zip
+// archive, by decompressing it as a stream. It is meant for reads that are
+// mostly sequential: reading before the current position restarts
+// decompression at the start of the file.
+type zipStreamReader struct {
+	file *zip.File
+	mu   sync.Mutex
+	rc   io.ReadCloser // decompressing reader, nil until the first read
+	pos  int64         // position of rc in the decompressed file
+}
+
+func (zr *zipStreamReader) ReadAt(p []byte, off int64) (int, error) {
+	zr.mu.Lock()
+	defer zr.mu.Unlock()
+
+	if zr.rc == nil || off < zr.pos {
+		if zr.rc != nil {
+			zr.rc.Close()
+			zr.rc = nil
+		}
+		rc, err := zr.file.Open()
+		if err != nil {
+			return 0, err
+		}
+		zr.rc = rc
+		zr.pos = 0
+	}
+	if off > zr.pos {
+		n, err := io.CopyN(ioutil.Discard, zr.rc, off-zr.pos)
+		zr.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := io.ReadFull(zr.rc, p)
+	zr.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close releases the decompressor
+func (zr *zipStreamReader) Close() error {
+	zr.mu.Lock()
+	defer zr.mu.Unlock()
+
+	if zr.rc == nil {
+		return nil
+	}
+	err := zr.rc.Close()
+	zr.rc = nil
+	return err
+}
+
+// newAppendedVirtualDir creates a virtualDir to list the given appended directory
+func newAppendedVirtualDir(name string, af *appendedFile) *virtualDir {
+	entries := make([]os.FileInfo, 0, len(af.children))
+	for _, child := range af.children {
+		entries = append(entries, child.info())
+	}
+	return newVirtualDir(name, af.info(), entries)
 }
 
 // implements os.FileInfo.
@@ -0,0 +1,8 @@
+//go:build rice_noappend
+// +build rice_noappend
+
+package rice
+
+// appendedDisabled is set by building with the rice_noappend tag, the
+// executable is never searched for appended boxes.
+const appendedDisabled = true
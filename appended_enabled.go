@@ -0,0 +1,7 @@
+//go:build !rice_noappend
+// +build !rice_noappend
+
+package rice
+
+// appendedDisabled is set by building with the rice_noappend tag, see appended_disabled.go
+const appendedDisabled = false
@@ -0,0 +1,82 @@
+//go:build linux
+// +build linux
+
+package rice
+
+import (
+	"errors"
+	"io"
+	"os"
+	"runtime/debug"
+	"syscall"
+)
+
+// errExecutableChanged is returned when the mapped executable can't be read
+// anymore, e.g. because it was truncated
+var errExecutableChanged = errors.New("rice: the executable was changed while it is running")
+
+// openExecutable opens the executable to read the appended zip archive from.
+// On linux the executable is mapped into memory, so stored files are read
+// straight from the page cache. When mapping fails the file is read directly.
+//
+// The mapping is private, but pages that were not read yet still come from
+// the file: when the executable is overwritten in place, later reads may
+// return the new contents, and when it is truncated they fault. A fault is
+// returned as errExecutableChanged instead of crashing the program. Replacing
+// the executable by renaming a new file over it, as package managers and
+// `go install` do, doesn't affect the mapping.
+func openExecutable(name string) (io.ReaderAt, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	size := info.Size()
+	if size <= 0 || int64(int(size)) != size {
+		return f, size, nil
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_PRIVATE)
+	if err != nil {
+		return f, size, nil
+	}
+	// the mapping stays valid after the file is closed
+	f.Close()
+	return &mappedFile{data: data}, size, nil
+}
+
+// mappedFile is a file that is mapped into memory
+type mappedFile struct {
+	data []byte
+}
+
+// ReadAt copies from the mapped file, a fault while copying (the file was
+// truncated) is returned as errExecutableChanged
+func (mf *mappedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("rice: negative offset")
+	}
+	if off >= int64(len(mf.data)) {
+		return 0, io.EOF
+	}
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if recover() != nil {
+			n, err = 0, errExecutableChanged
+		}
+	}()
+	n = copy(p, mf.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close unmaps the file
+func (mf *mappedFile) Close() error {
+	return syscall.Munmap(mf.data)
+}
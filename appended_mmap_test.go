@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+package rice
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMappedExecutableTruncated(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "executable")
+	content := bytes.Repeat([]byte("rice"), 4*os.Getpagesize())
+	if err := ioutil.WriteFile(name, content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exe, size, err := openExecutable(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeExecutable(exe)
+	if _, ok := exe.(*mappedFile); !ok {
+		t.Skipf("the executable is not mapped: %T", exe)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), size)
+	}
+
+	p := make([]byte, 8)
+	if _, err := exe.ReadAt(p, 4); err != nil || string(p) != "ricerice" {
+		t.Errorf("unexpected read %q, %v", p, err)
+	}
+
+	// reading the pages that were cut off faults
+	if err := os.Truncate(name, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := exe.ReadAt(p, size-8); err != errExecutableChanged {
+		t.Errorf("expected errExecutableChanged, got %v", err)
+	}
+}
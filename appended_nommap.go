@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package rice
+
+import (
+	"io"
+	"os"
+)
+
+// openExecutable opens the executable to read the appended zip archive from.
+func openExecutable(name string) (io.ReaderAt, int64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
@@ -0,0 +1,221 @@
+package rice
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/archive"
+)
+
+func TestAppendedRandomAccess(t *testing.T) {
+	box := newAppendedTestBox(t)
+	for name, content := range testBoxFiles {
+		if content == "" {
+			continue
+		}
+		af := box.appendd.Files[name]
+		vf, err := newAppendedVirtualFile(name, af)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		switch af.zipFile.Method {
+		case zip.Store:
+			if _, ok := vf.content.(*io.SectionReader); !ok {
+				t.Errorf("%s: stored file is read through %T, expected it to be read directly", name, vf.content)
+			}
+		default:
+			if _, ok := vf.content.(*zipStreamReader); !ok {
+				t.Errorf("%s: compressed file is read through %T, expected a zipStreamReader", name, vf.content)
+			}
+		}
+
+		// read forwards, backwards and skipping ahead
+		size := int64(len(content))
+		for _, offset := range []int64{size / 2, 0, size - 1, 1, size / 3, size / 3} {
+			buf := make([]byte, 3)
+			n, err := vf.readAt(buf, offset)
+			if err != nil && err != io.EOF {
+				t.Fatalf("%s: ReadAt(%d): %v", name, offset, err)
+			}
+			end := offset + 3
+			if end > size {
+				end = size
+			}
+			if string(buf[:n]) != content[offset:end] {
+				t.Errorf("%s: ReadAt(%d) = %q, expected %q", name, offset, buf[:n], content[offset:end])
+			}
+		}
+		if err := vf.close(); err != nil {
+			t.Errorf("%s: close: %v", name, err)
+		}
+	}
+}
+
+// writeAppendedExecutable writes a fake executable with a zip archive appended
+// the way `rice append` does, and returns its path. The archive is signed when
+// a key is given.
+func writeAppendedExecutable(t *testing.T, files map[string]string, key ed25519.PrivateKey) string {
+	prefix := []byte("\x7fELF not really an executable")
+	buf := bytes.NewBuffer(append([]byte{}, prefix...))
+	zw := zip.NewWriter(buf)
+	zw.SetOffset(int64(len(prefix)))
+	zw.SetComment(archive.Comment)
+	for name, content := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if key != nil {
+		signed, err := archive.Sign(data[len(prefix):], key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(prefix, signed...)
+	}
+
+	exe := filepath.Join(t.TempDir(), "exe")
+	if err := ioutil.WriteFile(exe, data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func TestOpenAppendedBoxes(t *testing.T) {
+	exe := writeAppendedExecutable(t, map[string]string{
+		"example.com%2Fpkg/box/file.txt": "appended content",
+		"not-namespaced.txt":             "ignored",
+	}, nil)
+	boxes, errs := openAppendedBoxes(exe)
+	if len(errs) != 1 {
+		t.Fatalf("expected one load error, got %v", errs)
+	}
+	var pathErr *os.PathError
+	if !errors.As(errs[0], &pathErr) || pathErr.Path != "not-namespaced.txt" {
+		t.Errorf("expected a load error for not-namespaced.txt, got %v", errs[0])
+	}
+
+	ab := boxes[embedded.BoxKey("example.com/pkg", "box")]
+	if ab == nil {
+		t.Fatalf("appended box not found, got %v", boxes)
+	}
+	box := &Box{name: "box", appendd: ab}
+	content, err := box.String("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "appended content" {
+		t.Errorf("read %q from appended box", content)
+	}
+
+	// an executable without anything appended is not an error
+	plain := filepath.Join(t.TempDir(), "plain")
+	if err := ioutil.WriteFile(plain, []byte("\x7fELF nothing appended"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	boxes, errs = openAppendedBoxes(plain)
+	if boxes != nil || errs != nil {
+		t.Errorf("expected no boxes and no errors, got %v, %v", boxes, errs)
+	}
+}
+
+func TestAppendedShortRead(t *testing.T) {
+	box := newAppendedTestBox(t)
+	const name = "sub/deeper/nested/d.txt"
+	af := box.appendd.Files[name]
+	if af.zipFile.Method != zip.Store {
+		t.Fatalf("%s is expected to be stored", name)
+	}
+
+	// cut off the archive in the middle of the file data
+	offset, err := af.zipFile.DataOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, offset+100)
+	if _, err := af.archive.ReadAt(data, 0); err != nil {
+		t.Fatal(err)
+	}
+	af.archive = bytes.NewReader(data)
+
+	f, err := box.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF after reading %d bytes, got %v", len(content), err)
+	}
+	if _, err := f.ReadAt(make([]byte, 10), 200); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadAt beyond the available data: expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestAppendedSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// boxes appended for this package
+	const namespace = "github.com/GeertJohan/go.rice"
+	files := map[string]string{archive.BoxDir(namespace, "signedbox") + "/file.txt": "content"}
+	signed, errs := openAppendedBoxes(writeAppendedExecutable(t, files, priv))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	unsigned, errs := openAppendedBoxes(writeAppendedExecutable(t, files, nil))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	key := embedded.BoxKey(namespace, "signedbox")
+	defer delete(appendedBoxes, key)
+	cases := []struct {
+		box      *appendedBox
+		key      ed25519.PublicKey
+		expected error
+	}{
+		{signed[key], nil, nil},
+		{signed[key], pub, nil},
+		{signed[key], otherPub, ErrInvalidSignature},
+		{unsigned[key], nil, nil},
+		{unsigned[key], pub, ErrNotSigned},
+	}
+	for i, c := range cases {
+		appendedBoxes[key] = c.box
+		cfg := Config{LocateOrder: []LocateMethod{LocateAppended}, AppendedPublicKey: c.key}
+		box, err := cfg.FindBox("signedbox")
+		if c.expected == nil {
+			if err != nil {
+				t.Errorf("case %d: %v", i, err)
+			} else if !box.IsAppended() {
+				t.Errorf("case %d: expected the appended box", i)
+			}
+			continue
+		}
+		if !errors.Is(err, c.expected) {
+			t.Errorf("case %d: expected %v, got %v", i, c.expected, err)
+		}
+	}
+}
@@ -1,14 +1,16 @@
 package rice
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/GeertJohan/go.rice/embedded"
@@ -17,81 +19,108 @@ import (
 // Box abstracts a directory for resources/files.
 // It can either load files from disk, or from embedded code (when `rice --embed` was ran).
 type Box struct {
-	name         string
-	absolutePath string
-	embed        *embedded.EmbeddedBox
-	appendd      *appendedBox
+	name              string
+	absolutePath      string
+	embed             *embedded.EmbeddedBox
+	appendd           *appendedBox
+	denySymlinkEscape bool
+
+	// decryption key for encrypted files, see Config.DecryptionKey
+	decryptionKeyFunc func() ([]byte, error)
+	decryptionKeyMu   sync.Mutex
+	decryptionKey     []byte
+
+	// hashes computed by Hash
+	hashes hashCache
+
+	// how often Watch checks the box on disk for changes, see Config.WatchInterval
+	watchInterval time.Duration
+
+	// layers of an overlay box, see Overlay
+	overlay []*Box
 }
 
+// defaultLocateOrder is used by FindBox and MustFindBox, unless EnvLocateOrder is set.
+// LocateEnv is left out, so the environment can't replace the boxes of every
+// program; it's opt-in through a LocateOrder.
 var defaultLocateOrder = []LocateMethod{LocateEmbedded, LocateAppended, LocateFS}
 
-func findBox(name string, order []LocateMethod) (*Box, error) {
-	b := &Box{name: name}
-
+// findBox locates the box with the config, or with the default LocateOrder
+// when cfg is nil.
+func findBox(name string, cfg *Config) (*Box, error) {
 	// no support for absolute paths since gopath can be different on different machines.
 	// therefore, required box must be located relative to package requiring it.
 	if filepath.IsAbs(name) {
 		return nil, errors.New("given name/path is absolute")
 	}
+	if cfg == nil {
+		order, err := defaultLocateOrderFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		cfg = &Config{LocateOrder: order}
+	}
 
-	var err error
-	for _, method := range order {
-		switch method {
-		case LocateEmbedded:
-			if embed := embedded.EmbeddedBoxes[name]; embed != nil {
-				b.embed = embed
-				return b, nil
-			}
-
-		case LocateAppended:
-			appendedBoxName := strings.Replace(name, `/`, `-`, -1)
-			if appendd := appendedBoxes[appendedBoxName]; appendd != nil {
-				b.appendd = appendd
-				return b, nil
-			}
-
-		case LocateFS:
-			// resolve absolute directory path
-			err := b.resolveAbsolutePathFromCaller()
-			if err != nil {
-				continue
-			}
-			// check if absolutePath exists on filesystem
-			info, err := os.Stat(b.absolutePath)
-			if err != nil {
-				continue
-			}
-			// check if absolutePath is actually a directory
-			if !info.IsDir() {
-				err = errors.New("given name/path is not a directory")
-				continue
-			}
-			return b, nil
-		case LocateWorkingDirectory:
-			// resolve absolute directory path
-			err := b.resolveAbsolutePathFromWorkingDirectory()
-			if err != nil {
-				continue
-			}
-			// check if absolutePath exists on filesystem
-			info, err := os.Stat(b.absolutePath)
-			if err != nil {
-				continue
-			}
-			// check if absolutePath is actually a directory
-			if !info.IsDir() {
-				err = errors.New("given name/path is not a directory")
-				continue
+	// the caller is looked up here, so locators don't depend on the depth of the stack:
+	// findBox is called by FindBox, MustFindBox or the methods of Config
+	req := &LocateRequest{
+		Name: name,
+		// embedded and appended boxes are registered under the import path of
+		// the package that uses them
+		Package: callerPackage(3),
+		Config:  cfg,
+	}
+	req.callerPath, req.callerPathErr = resolveAbsolutePathFromCaller(name, 3)
+
+	locateErr := &LocateError{Name: name}
+	for _, method := range cfg.LocateOrder {
+		locator := lookupLocator(method)
+		if locator == nil {
+			locateErr.Failures = append(locateErr.Failures, LocateFailure{method, errors.New("no such locate method")})
+			continue
+		}
+		b, err := locator.Locate(req)
+		if err == nil && b == nil {
+			err = ErrBoxNotFound
+		}
+		if err != nil {
+			if Debug {
+				fmt.Printf("Box %q not located by %s: %v\n", name, method, err)
 			}
-			return b, nil
+			locateErr.Failures = append(locateErr.Failures, LocateFailure{method, err})
+			continue
+		}
+		if Debug {
+			fmt.Printf("Box %q located by %s\n", name, method)
 		}
+		return b, nil
 	}
+	return nil, locateErr
+}
 
-	if err == nil {
-		err = fmt.Errorf("could not locate box %q", name)
+// findEmbeddedBox returns the box embedded for the package with the given
+// import path, or a box embedded by an older version of rice.
+func findEmbeddedBox(namespace, name string) *embedded.EmbeddedBox {
+	if embed := embedded.EmbeddedBoxes[embedded.BoxKey(namespace, name)]; embed != nil {
+		return embed
 	}
+	return embedded.EmbeddedBoxes[name]
+}
 
-	return nil, err
+// findAppendedBox returns the box appended for the package with the given
+// import path, or a box appended by an older version of rice.
+func findAppendedBox(namespace, name string) *appendedBox {
+	return findArchivedBox(appendedBoxes, namespace, name)
+}
+
+// findArchivedBox returns the box for the package with the given import path
+// from the boxes loaded from an archive, or a box stored by an older version
+// of rice.
+func findArchivedBox(boxes map[string]*appendedBox, namespace, name string) *appendedBox {
+	if appendd := boxes[embedded.BoxKey(namespace, name)]; appendd != nil {
+		return appendd
+	}
+	return boxes[strings.Replace(name, `/`, `-`, -1)]
 }
 
 // FindBox returns a Box instance for given name.
@@ -99,13 +128,13 @@ func findBox(name string, order []LocateMethod) (*Box, error) {
 // When the given name is absolute, it's absolute. derp.
 // Make sure the path doesn't contain any sensitive information as it might be placed into generated go source (embedded).
 func FindBox(name string) (*Box, error) {
-	return findBox(name, defaultLocateOrder)
+	return findBox(name, nil)
 }
 
 // MustFindBox returns a Box instance for given name, like FindBox does.
 // It does not return an error, instead it panics when an error occurs.
 func MustFindBox(name string) *Box {
-	box, err := findBox(name, defaultLocateOrder)
+	box, err := findBox(name, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -133,23 +162,33 @@ var resolveAbsolutePathFromCaller = func(name string, nStackFrames int) (string,
 	return filepath.Join(pkgDir, name), nil
 }
 
-func (b *Box) resolveAbsolutePathFromCaller() error {
-	path, err := resolveAbsolutePathFromCaller(b.name, 4)
-	if err != nil {
-		return err
+// callerPackage returns the import path of the package of the function
+// nStackFrames up the stack, "main" for commands.
+func callerPackage(nStackFrames int) string {
+	pc := make([]uintptr, 1)
+	if runtime.Callers(nStackFrames+1, pc) == 0 {
+		return ""
 	}
-	b.absolutePath = path
-	return nil
-
+	frame, _ := runtime.CallersFrames(pc).Next()
+	return funcPackage(frame.Function)
 }
 
-func (b *Box) resolveAbsolutePathFromWorkingDirectory() error {
-	path, err := os.Getwd()
-	if err != nil {
-		return err
+// funcPackage returns the import path of the package from a fully qualified
+// function name, e.g. "github.com/user/go%2epkg.(*T).Method.func1". The
+// functions of an external test package (package web_test) belong to the
+// package under test, so they find the boxes the rice tool embedded for it.
+func funcPackage(funcName string) string {
+	lastSlash := strings.LastIndex(funcName, "/")
+	dot := strings.Index(funcName[lastSlash+1:], ".")
+	if dot < 0 {
+		return ""
 	}
-	b.absolutePath = filepath.Join(path, b.name)
-	return nil
+	pkg := funcName[:lastSlash+1+dot]
+	// the linker escapes dots (and some other characters) in the last element of the import path
+	if unescaped, err := url.PathUnescape(pkg); err == nil {
+		pkg = unescaped
+	}
+	return strings.TrimSuffix(pkg, "_test")
 }
 
 // IsEmbedded indicates wether this box was embedded into the application
@@ -157,7 +196,8 @@ func (b *Box) IsEmbedded() bool {
 	return b.embed != nil
 }
 
-// IsAppended indicates wether this box was appended to the application
+// IsAppended indicates wether this box was appended to the application, or
+// loaded from a bundle (see Config.Bundles)
 func (b *Box) IsAppended() bool {
 	return b.appendd != nil
 }
@@ -174,6 +214,10 @@ func (b *Box) Time() time.Time {
 		return b.appendd.Time
 	}
 
+	if b.isOverlay() {
+		return b.overlayTime()
+	}
+
 	return time.Now()
 }
 
@@ -184,14 +228,19 @@ func (b *Box) Open(name string) (*File, error) {
 		fmt.Printf("Open(%s)\n", name)
 	}
 
+	// paths are relative to box
+	name, err := cleanName("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.isOverlay() {
+		return b.openOverlay(name)
+	}
+
 	if b.IsEmbedded() {
 		if Debug {
 			fmt.Println("Box is embedded")
-		}
-
-		// trim prefix (paths are relative to box)
-		name = strings.TrimLeft(name, "/")
-		if Debug {
 			fmt.Printf("Trying %s\n", name)
 		}
 
@@ -217,22 +266,28 @@ func (b *Box) Open(name string) (*File, error) {
 			if Debug {
 				fmt.Println("Found dir. Returning virtual dir")
 			}
-			vd := newVirtualDir(ed)
-			return &File{virtualD: vd}, nil
+			return &File{virtualD: newEmbeddedVirtualDir(ed)}, nil
 		}
 
 		// box is embedded
 		if Debug {
 			fmt.Println("Found file. Returning virtual file")
 		}
-		vf := newVirtualFile(ef)
+		if ef.Encrypted {
+			return b.openEncrypted(name, (*embeddedFileInfo)(ef), []byte(ef.Content), ef.Compressed)
+		}
+		vf, err := newEmbeddedVirtualFile(ef)
+		if err != nil {
+			return nil, &os.PathError{
+				Op:   "open",
+				Path: name,
+				Err:  err,
+			}
+		}
 		return &File{virtualF: vf}, nil
 	}
 
 	if b.IsAppended() {
-		// trim prefix (paths are relative to box)
-		name = strings.TrimLeft(name, "/")
-
 		// search for file
 		appendedFile := b.appendd.Files[name]
 		if appendedFile == nil {
@@ -243,40 +298,129 @@ func (b *Box) Open(name string) (*File, error) {
 			}
 		}
 
-		// create new file
-		f := &File{
-			appendedF: appendedFile,
+		if appendedFile.dir {
+			return &File{virtualD: newAppendedVirtualDir(name, appendedFile)}, nil
 		}
 
-		// if this file is a directory, we want to be able to read and seek
-		if !appendedFile.dir {
-			// looks like malformed data in zip, error now
-			if appendedFile.content == nil {
+		if appendedFile.encrypted {
+			encrypted, err := appendedFile.readAll()
+			if err != nil {
 				return nil, &os.PathError{
 					Op:   "open",
-					Path: "name",
-					Err:  errors.New("error reading data from zip file"),
+					Path: name,
+					Err:  err,
 				}
 			}
-			// create new bytes.Reader
-			f.appendedFileReader = bytes.NewReader(appendedFile.content)
+			return b.openEncrypted(name, appendedFile.info(), encrypted, false)
 		}
 
-		// all done
-		return f, nil
+		vf, err := newAppendedVirtualFile(name, appendedFile)
+		if err != nil {
+			return nil, &os.PathError{
+				Op:   "open",
+				Path: name,
+				Err:  err,
+			}
+		}
+		return &File{virtualF: vf}, nil
 	}
 
 	// perform os open
+	fullPath := filepath.Join(b.absolutePath, filepath.FromSlash(name))
 	if Debug {
-		fmt.Printf("Using os.Open(%s)", filepath.Join(b.absolutePath, name))
+		fmt.Printf("Using os.Open(%s)", fullPath)
+	}
+	if b.denySymlinkEscape {
+		if err := b.checkSymlinks(fullPath); err != nil {
+			return nil, err
+		}
 	}
-	file, err := os.Open(filepath.Join(b.absolutePath, name))
+	file, err := os.Open(fullPath)
 	if err != nil {
 		return nil, err
 	}
 	return &File{realF: file}, nil
 }
 
+// stat returns the os.FileInfo for the file or directory with the given
+// (clean) name, without opening it. Encrypted files are not decrypted.
+func (b *Box) stat(name string) (os.FileInfo, error) {
+	if b.isOverlay() {
+		_, info, err := b.overlayStat(name)
+		return info, err
+	}
+
+	if b.IsEmbedded() {
+		if ef := b.embed.Files[name]; ef != nil {
+			return (*embeddedFileInfo)(ef), nil
+		}
+		if ed := b.embed.Dirs[name]; ed != nil {
+			return (*embeddedDirInfo)(ed), nil
+		}
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	if b.IsAppended() {
+		if af := b.appendd.Files[name]; af != nil {
+			return af.info(), nil
+		}
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+
+	fullPath := filepath.Join(b.absolutePath, filepath.FromSlash(name))
+	if b.denySymlinkEscape {
+		if err := b.checkSymlinks(fullPath); err != nil {
+			return nil, err
+		}
+	}
+	return os.Stat(fullPath)
+}
+
+// ErrPathEscape is the error (wrapped in an *os.PathError) that is returned
+// when a name refers to a location outside of the box.
+var ErrPathEscape = errors.New("path escapes from box")
+
+// cleanName normalizes the name of a file within a box: both "/sub/file" and
+// "sub/./file" become "sub/file", the root of the box is "".
+// Every backend uses it, so a name that would escape the box (e.g.
+// "../secret") fails the same way for embedded, appended and live boxes.
+func cleanName(op, name string) (string, error) {
+	cleaned := path.Clean(strings.TrimLeft(filepath.ToSlash(name), "/"))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") || filepath.VolumeName(cleaned) != "" {
+		return "", &os.PathError{
+			Op:   op,
+			Path: name,
+			Err:  ErrPathEscape,
+		}
+	}
+	if cleaned == "." {
+		return "", nil
+	}
+	return cleaned, nil
+}
+
+// checkSymlinks verifies that fullPath doesn't resolve to a location outside
+// of the box directory through a symbolic link.
+func (b *Box) checkSymlinks(fullPath string) error {
+	root, err := filepath.EvalSymlinks(b.absolutePath)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(fullPath)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return &os.PathError{
+			Op:   "open",
+			Path: fullPath,
+			Err:  ErrPathEscape,
+		}
+	}
+	return nil
+}
+
 // Bytes returns the content of the file with given name as []byte.
 func (b *Box) Bytes(name string) ([]byte, error) {
 	file, err := b.Open(name)
@@ -307,13 +451,22 @@ func (b *Box) MustBytes(name string) []byte {
 func (b *Box) String(name string) (string, error) {
 	// check if box is embedded, optimized fast path
 	if b.IsEmbedded() {
-		// find file in embed
-		ef := b.embed.Files[name]
-		if ef == nil {
-			return "", os.ErrNotExist
+		// find file in embed, directories and missing files take the slow
+		// path so the error is the same as with Open
+		if cleaned, err := cleanName("open", name); err == nil {
+			if ef := b.embed.Files[cleaned]; ef != nil && !ef.Encrypted {
+				// return as string
+				content, err := ef.Uncompressed()
+				if err != nil {
+					return "", &os.PathError{
+						Op:   "open",
+						Path: cleaned,
+						Err:  err,
+					}
+				}
+				return content, nil
+			}
 		}
-		// return as string
-		return ef.Content, nil
 	}
 
 	bts, err := b.Bytes(name)
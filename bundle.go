@@ -0,0 +1,125 @@
+package rice
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GeertJohan/go.rice/internal/archive"
+)
+
+// bundle is a .rice file written by `rice pack`, with the boxes of one or
+// more packages
+type bundle struct {
+	mu     sync.Mutex
+	loaded bool
+	boxes  map[string]*appendedBox
+}
+
+var (
+	bundlesMu sync.Mutex
+	bundles   = make(map[string]*bundle) // by absolute path
+)
+
+// openBundle returns the boxes in the bundle file. A bundle is loaded once,
+// the file stays open for the lifetime of the process. A bundle that fails to
+// load (e.g. it doesn't exist yet) is loaded again the next time.
+func openBundle(name string) (map[string]*appendedBox, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	bundlesMu.Lock()
+	b := bundles[abs]
+	if b == nil {
+		b = &bundle{}
+		bundles[abs] = b
+	}
+	bundlesMu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.loaded {
+		boxes, err := loadBundle(abs)
+		if err != nil {
+			return nil, err
+		}
+		b.boxes, b.loaded = boxes, true
+	}
+	return b.boxes, nil
+}
+
+// loadBundle reads the boxes from the bundle file with the given name
+func loadBundle(name string) (map[string]*appendedBox, error) {
+	// the file isn't mapped into memory, as it may be replaced while the
+	// process runs
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	rd, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "load", Path: name, Err: err}
+	}
+	if !archive.IsNamespaced(rd.Comment) {
+		f.Close()
+		return nil, &os.PathError{Op: "load", Path: name, Err: errors.New("not a bundle written by rice pack")}
+	}
+	boxes, errs := loadAppendedBoxes(f, info.Size(), rd)
+	if len(errs) > 0 {
+		// unlike an executable, a bundle holds nothing else, so it's broken
+		f.Close()
+		return nil, errs[0]
+	}
+	return boxes, nil
+}
+
+// bundleLocator implements LocateBundle
+type bundleLocator struct{}
+
+func (bundleLocator) Locate(req *LocateRequest) (*Box, error) {
+	if len(req.Config.Bundles) == 0 {
+		return nil, fmt.Errorf("%w, no bundles configured", ErrBoxNotFound)
+	}
+	// a bundle that is broken doesn't keep the box from being located in the
+	// bundles after it
+	var errs []error
+	for _, name := range req.Config.Bundles {
+		boxes, err := openBundle(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		appendd := findArchivedBox(boxes, req.Package, req.Name)
+		if appendd == nil {
+			continue
+		}
+		if req.Config.AppendedPublicKey != nil {
+			if err := appendd.archive.verify(req.Config.AppendedPublicKey); err != nil {
+				errs = append(errs, fmt.Errorf("refusing box from bundle %s: %w", name, err))
+				continue
+			}
+		}
+		b := req.newBox()
+		b.appendd = appendd
+		return b, nil
+	}
+	if len(errs) > 0 {
+		err := errs[0]
+		for _, other := range errs[1:] {
+			err = fmt.Errorf("%w; %v", err, other)
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w for package %q in bundles %s", ErrBoxNotFound, req.Package, strings.Join(req.Config.Bundles, ", "))
+}
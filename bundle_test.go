@@ -0,0 +1,179 @@
+package rice
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GeertJohan/go.rice/internal/archive"
+)
+
+// writeBundle writes a bundle the way `rice pack` does, with the given files
+// in a box of this package, and returns its path. The bundle is signed when a
+// key is given.
+func writeBundle(t *testing.T, box string, files map[string]string, key ed25519.PrivateKey) string {
+	const namespace = "github.com/GeertJohan/go.rice"
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	zw.SetComment(archive.Comment)
+	for _, dir := range testBoxDirs(files) {
+		name := archive.BoxDir(namespace, box)
+		if dir != "" {
+			name += "/" + dir
+		}
+		if _, err := zw.CreateHeader(&zip.FileHeader{Name: name, Comment: archive.DirAttr}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		w, err := zw.Create(archive.BoxDir(namespace, box) + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if key != nil {
+		var err error
+		if data, err = archive.Sign(data, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bundle := filepath.Join(t.TempDir(), "assets.rice")
+	if err := ioutil.WriteFile(bundle, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestLocateBundle(t *testing.T) {
+	first := writeBundle(t, "bundled", map[string]string{"file.txt": "first", "sub/a.txt": "a"}, nil)
+	second := writeBundle(t, "bundled", map[string]string{"file.txt": "second"}, nil)
+	other := writeBundle(t, "other", map[string]string{"file.txt": "other"}, nil)
+
+	cfg := Config{LocateOrder: []LocateMethod{LocateBundle}, Bundles: []string{other, first, second}}
+	box, err := cfg.FindBox("bundled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !box.IsAppended() {
+		t.Error("expected a box loaded from a bundle to be appended")
+	}
+	for name, expected := range map[string]string{"file.txt": "first", "sub/a.txt": "a"} {
+		content, err := box.String(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, content)
+		}
+	}
+
+	_, err = cfg.FindBox("missing")
+	if !errors.Is(err, ErrBoxNotFound) || !strings.Contains(err.Error(), "bundle: box not found") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	cfg.Bundles = []string{filepath.Join(t.TempDir(), "missing.rice")}
+	if _, err := cfg.FindBox("bundled"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected not exist for a missing bundle, got %v", err)
+	}
+
+	notBundle := filepath.Join(t.TempDir(), "broken.rice")
+	if err := ioutil.WriteFile(notBundle, []byte("not a zip archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Bundles = []string{notBundle}
+	if _, err := cfg.FindBox("bundled"); !errors.Is(err, zip.ErrFormat) {
+		t.Errorf("expected zip.ErrFormat for a broken bundle, got %v", err)
+	}
+
+	// broken bundles are skipped when a later bundle has the box
+	cfg.Bundles = []string{notBundle, filepath.Join(t.TempDir(), "missing.rice"), second}
+	box, err = cfg.FindBox("bundled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := box.String("file.txt"); content != "second" {
+		t.Errorf("expected the box of the valid bundle, got %q", content)
+	}
+}
+
+func TestLocateBundleLoadedAgain(t *testing.T) {
+	valid := writeBundle(t, "latebundle", map[string]string{"file.txt": "late"}, nil)
+	late := filepath.Join(t.TempDir(), "late.rice")
+	cfg := Config{LocateOrder: []LocateMethod{LocateBundle}, Bundles: []string{late}}
+
+	if _, err := cfg.FindBox("latebundle"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist for a missing bundle, got %v", err)
+	}
+
+	// the bundle is loaded once it's written
+	data, err := ioutil.ReadFile(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(late, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	box, err := cfg.FindBox("latebundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := box.String("file.txt"); content != "late" {
+		t.Errorf("expected the content of the bundle, got %q", content)
+	}
+}
+
+func TestLocateBundleSigned(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"file.txt": "content"}
+	signed := writeBundle(t, "signedbundle", files, priv)
+	unsigned := writeBundle(t, "signedbundle", files, nil)
+
+	cases := []struct {
+		bundle   string
+		key      ed25519.PublicKey
+		expected error
+	}{
+		{signed, nil, nil},
+		{signed, pub, nil},
+		{signed, otherPub, ErrInvalidSignature},
+		{unsigned, nil, nil},
+		{unsigned, pub, ErrNotSigned},
+	}
+	for i, c := range cases {
+		cfg := Config{LocateOrder: []LocateMethod{LocateBundle}, Bundles: []string{c.bundle}, AppendedPublicKey: c.key}
+		box, err := cfg.FindBox("signedbundle")
+		if c.expected == nil {
+			if err != nil {
+				t.Errorf("case %d: %v", i, err)
+			} else if content, _ := box.String("file.txt"); content != "content" {
+				t.Errorf("case %d: unexpected content %q", i, content)
+			}
+			continue
+		}
+		if !errors.Is(err, c.expected) {
+			t.Errorf("case %d: expected %v, got %v", i, c.expected, err)
+		}
+	}
+}
@@ -1,6 +1,12 @@
 package rice
 
-// LocateMethod defines how a box is located.
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+// LocateMethod defines how a box is located. Besides the built-in methods,
+// every Locator registered with RegisterLocator is a LocateMethod.
 type LocateMethod int
 
 const (
@@ -8,30 +14,80 @@ const (
 	LocateAppended                              // Locate boxes appended to the executable.
 	LocateEmbedded                              // Locate embedded boxes.
 	LocateWorkingDirectory                      // Locate on the binary working directory
+	LocateBundle                                // Locate in the bundles of Config.Bundles.
+	LocateEnv                                   // Locate in a directory set by an environment variable, see EnvBoxPrefix. Never used when AppendedPublicKey is set.
 )
 
 // Config allows customizing the box lookup behavior.
 type Config struct {
 	// LocateOrder defines the priority order that boxes are searched for. By
 	// default, the package global FindBox searches for embedded boxes first,
-	// then appended boxes, and then finally boxes on the filesystem.  That
-	// search order may be customized by provided the ordered list here. Leaving
-	// out a particular method will omit that from the search space. For
-	// example, []LocateMethod{LocateEmbedded, LocateAppended} will never search
-	// the filesystem for boxes.
+	// then appended boxes, and then finally boxes on the filesystem (see
+	// EnvLocateOrder).  That search order may be customized by provided the
+	// ordered list here. Leaving out a particular method will omit that from
+	// the search space. For example, []LocateMethod{LocateEmbedded,
+	// LocateAppended} will never search the filesystem for boxes. When no
+	// method locates a box, FindBox returns a *LocateError with the reason of
+	// every method.
+	//
+	// Overrides in the environment (LocateEnv) are not in the default order,
+	// they are only searched when LocateEnv is in the LocateOrder.
 	LocateOrder []LocateMethod
+
+	// DenySymlinkEscape makes boxes that are located on the filesystem refuse
+	// to open files through symbolic links that point outside of the box
+	// directory. Embedded and appended boxes never contain symbolic links.
+	DenySymlinkEscape bool
+
+	// AppendedPublicKey makes FindBox refuse appended boxes and boxes from
+	// bundles that are not signed with the matching private key, see `rice
+	// append --sign-key` and `rice pack --sign-key`. Overrides in the
+	// environment can't be signed, so LocateEnv is skipped.
+	// When a box is refused, the next LocateMethod is tried. If no other
+	// method locates the box, the error matches the verification error with
+	// errors.Is.
+	AppendedPublicKey ed25519.PublicKey
+
+	// Bundles are the paths of .rice files written by `rice pack`, which
+	// LocateBundle searches in this order. A bundle is opened when it is
+	// first searched, and stays open.
+	Bundles []string
+
+	// DecryptionKey is the AES key to decrypt files that were encrypted with
+	// `rice embed-go --encrypt-key` or `rice append --encrypt-key`. Encrypted
+	// files are decrypted when they are opened.
+	DecryptionKey []byte
+
+	// DecryptionKeyFunc provides the decryption key when DecryptionKey is
+	// not set. It is called when an encrypted file is opened, until it
+	// returns a key.
+	DecryptionKeyFunc func() ([]byte, error)
+
+	// WatchInterval is how often Box.Watch checks a box on disk for
+	// changes. Defaults to one second.
+	WatchInterval time.Duration
+}
+
+// decryptionKeyFunc returns the function that provides the decryption key,
+// nil when there is no key.
+func (c *Config) decryptionKeyFunc() func() ([]byte, error) {
+	if len(c.DecryptionKey) > 0 {
+		key := c.DecryptionKey
+		return func() ([]byte, error) { return key, nil }
+	}
+	return c.DecryptionKeyFunc
 }
 
 // FindBox searches for boxes using the LocateOrder of the config.
 func (c *Config) FindBox(boxName string) (*Box, error) {
-	return findBox(boxName, c.LocateOrder)
+	return findBox(boxName, c)
 }
 
 // MustFindBox searches for boxes using the LocateOrder of the config, like
 // FindBox does.  It does not return an error, instead it panics when an error
 // occurs.
 func (c *Config) MustFindBox(boxName string) *Box {
-	box, err := findBox(boxName, c.LocateOrder)
+	box, err := findBox(boxName, c)
 	if err != nil {
 		panic(err)
 	}
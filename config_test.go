@@ -1,17 +1,28 @@
 package rice
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/archive"
 )
 
 // For all test code in this package, define a set of test boxes.
 var eb1 *embedded.EmbeddedBox
 var ab1, ab2 *appendedBox
 var fsb1, fsb2, fsb3 string // paths to filesystem boxes
+
+// the real lookup of the caller, replaced in init
+var realResolveAbsolutePathFromCaller = resolveAbsolutePathFromCaller
+
 func init() {
 	var err error
 
@@ -134,3 +145,221 @@ func TestConfigLocateOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestNamespacedEmbeddedBoxes(t *testing.T) {
+	ours := &embedded.EmbeddedBox{Name: "shared", Package: "github.com/GeertJohan/go.rice"}
+	theirs := &embedded.EmbeddedBox{Name: "shared", Package: "example.com/other"}
+	// registering the same name for two packages must not panic
+	embedded.RegisterEmbeddedBox(theirs.Name, theirs)
+	embedded.RegisterEmbeddedBox(ours.Name, ours)
+
+	cfg := Config{LocateOrder: []LocateMethod{LocateEmbedded}}
+	b, err := cfg.FindBox("shared")
+	if err != nil {
+		t.Fatalf("Expected to find box shared, got error: %v", err)
+	}
+	if b.embed != ours {
+		t.Fatalf("Expected to find the box embedded for this package, but got %#v", b.embed)
+	}
+}
+
+func TestNamespacedAppendedBoxes(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	if err := zw.SetComment(archive.Comment); err != nil {
+		t.Fatal(err)
+	}
+	files := []struct{ namespace, box, content string }{
+		{"github.com/GeertJohan/go.rice", "a/b", "slash"},
+		{"github.com/GeertJohan/go.rice", "a-b", "dash"},
+		{"example.com/other", "a/b", "other package"},
+	}
+	for _, file := range files {
+		dir := &zip.FileHeader{Name: archive.BoxDir(file.namespace, file.box), Comment: "dir"}
+		if _, err := zw.CreateHeader(dir); err != nil {
+			t.Fatal(err)
+		}
+		w, err := zw.Create(archive.BoxDir(file.namespace, file.box) + "/file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(file.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ra := bytes.NewReader(buf.Bytes())
+	rd, err := zip.NewReader(ra, ra.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boxes, errs := loadAppendedBoxes(ra, ra.Size(), rd)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(boxes) != len(files) {
+		t.Fatalf("Expected %d appended boxes, got %d", len(files), len(boxes))
+	}
+	for _, file := range files {
+		ab := boxes[embedded.BoxKey(file.namespace, file.box)]
+		if ab == nil {
+			t.Fatalf("Expected to find box %q for package %q", file.box, file.namespace)
+		}
+		content, err := (&Box{appendd: ab}).String("file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != file.content {
+			t.Errorf("Expected content %q for box %q of package %q, got %q", file.content, file.box, file.namespace, content)
+		}
+	}
+}
+
+func TestFuncPackage(t *testing.T) {
+	for funcName, expected := range map[string]string{
+		"main.main":         "main",
+		"main.init.0.func1": "main",
+		"github.com/GeertJohan/go%2erice.FindBox":    "github.com/GeertJohan/go.rice",
+		"example.com/app/web.(*Server).routes.func2": "example.com/app/web",
+		"example.com/app/v2/web.Handler[...]":        "example.com/app/v2/web",
+		"gopkg.in/yaml%2ev2.Unmarshal":               "gopkg.in/yaml.v2",
+		"example.com/app/web_test.TestServer":        "example.com/app/web",
+		"example.com/app/web_test.init.func1":        "example.com/app/web",
+	} {
+		if pkg := funcPackage(funcName); pkg != expected {
+			t.Errorf("funcPackage(%q) = %q, expected %q", funcName, pkg, expected)
+		}
+	}
+}
+
+// fixtureBox is located by LocateFixture, for any name
+var fixtureBox = &embedded.EmbeddedBox{
+	Name:  "fixture",
+	Files: map[string]*embedded.EmbeddedFile{"file.txt": {Filename: "file.txt", Content: "fixture"}},
+	Dirs:  map[string]*embedded.EmbeddedDir{"": {Filename: ""}},
+}
+
+// lastFixtureRequest is the last request of LocateFixture
+var lastFixtureRequest *LocateRequest
+
+var LocateFixture = RegisterLocator("fixture", LocatorFunc(func(req *LocateRequest) (*Box, error) {
+	lastFixtureRequest = req
+	if req.Name == "missing" {
+		return nil, errors.New("no fixture for missing")
+	}
+	return req.EmbeddedBox(fixtureBox), nil
+}))
+
+func TestRegisterLocator(t *testing.T) {
+	if LocateFixture.String() != "fixture" {
+		t.Errorf("unexpected name %q", LocateFixture.String())
+	}
+
+	cfg := Config{LocateOrder: []LocateMethod{LocateFixture, LocateEmbedded}, DecryptionKey: []byte("key")}
+	b, err := cfg.FindBox("box3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := b.String("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "fixture" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if b.decryptionKeyFunc == nil {
+		t.Error("expected the box to have the settings of the config")
+	}
+	if lastFixtureRequest.Package != "github.com/GeertJohan/go.rice" {
+		t.Errorf("unexpected package %q", lastFixtureRequest.Package)
+	}
+
+	// the built-in locators come first when they're listed first
+	cfg.LocateOrder = []LocateMethod{LocateEmbedded, LocateFixture}
+	b, err = cfg.FindBox("box1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.embed != eb1 {
+		t.Fatalf("Expected to find embedded box, but got %#v", b)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for a name that is registered twice")
+			}
+		}()
+		RegisterLocator("embedded", LocatorFunc(func(*LocateRequest) (*Box, error) { return nil, nil }))
+	}()
+}
+
+func TestLocateError(t *testing.T) {
+	cfg := Config{LocateOrder: []LocateMethod{LocateFixture, LocateEmbedded, LocateFS, LocateMethod(-1)}}
+	_, err := cfg.FindBox("missing")
+	var locateErr *LocateError
+	if !errors.As(err, &locateErr) {
+		t.Fatalf("expected a *LocateError, got %v", err)
+	}
+	if len(locateErr.Failures) != 4 {
+		t.Fatalf("expected a failure for every method, got %v", locateErr.Failures)
+	}
+	for i, method := range cfg.LocateOrder {
+		if locateErr.Failures[i].Method != method {
+			t.Errorf("failure %d: expected method %v, got %v", i, method, locateErr.Failures[i].Method)
+		}
+	}
+	for _, reason := range []string{
+		`could not locate box "missing": fixture: no fixture for missing`,
+		`embedded: box not found for package "github.com/GeertJohan/go.rice"`,
+		`fs: Unknown box name: "missing"`,
+		`LocateMethod(-1): no such locate method`,
+	} {
+		if !strings.Contains(err.Error(), reason) {
+			t.Errorf("expected %q in %q", reason, err)
+		}
+	}
+	if !errors.Is(err, ErrBoxNotFound) {
+		t.Error("expected the error to match ErrBoxNotFound")
+	}
+}
+
+func TestLocateCallerPath(t *testing.T) {
+	mock := resolveAbsolutePathFromCaller
+	resolveAbsolutePathFromCaller = realResolveAbsolutePathFromCaller
+	defer func() { resolveAbsolutePathFromCaller = mock }()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(wd, "fixture")
+
+	// locators get the caller's path, whichever function was called
+	cfg := Config{LocateOrder: []LocateMethod{LocateFixture}}
+	for name, find := range map[string]func(){
+		"Config.FindBox":     func() { cfg.FindBox("fixture") },
+		"Config.MustFindBox": func() { cfg.MustFindBox("fixture") },
+	} {
+		lastFixtureRequest = nil
+		find()
+		if lastFixtureRequest.callerPath != expected {
+			t.Errorf("%s: expected caller path %q, got %q", name, expected, lastFixtureRequest.callerPath)
+		}
+	}
+
+	// the default order fails to locate the box on disk at that path
+	_, err = FindBox("fixture")
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("FindBox: expected %q in the error, got %v", expected, err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), expected) {
+				t.Errorf("MustFindBox: expected %q in the panic, got %v", expected, r)
+			}
+		}()
+		MustFindBox("fixture")
+	}()
+}
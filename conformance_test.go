@@ -0,0 +1,376 @@
+package rice
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// The tests in this file run the same cases against a box from every
+// LocateMethod. The boxes on disk are served by *os.File, so they define the
+// expected behavior for the embedded and appended boxes.
+
+func forEachTestBox(t *testing.T, fn func(t *testing.T, box *Box)) {
+	for method, box := range newTestBoxes(t) {
+		box := box
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			fn(t, box)
+		})
+	}
+}
+
+func mustOpen(t *testing.T, box *Box, name string) *File {
+	f, err := box.Open(name)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", name, err)
+	}
+	return f
+}
+
+func TestFileRead(t *testing.T) {
+	forEachTestBox(t, func(t *testing.T, box *Box) {
+		for name, expected := range testBoxFiles {
+			f := mustOpen(t, box, name)
+
+			// small reads until EOF
+			var content []byte
+			buf := make([]byte, 7)
+			for {
+				n, err := f.Read(buf)
+				content = append(content, buf[:n]...)
+				if err == io.EOF {
+					if n != 0 {
+						t.Errorf("%s: Read returned %d bytes together with EOF", name, n)
+					}
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s: Read: %v", name, err)
+				}
+			}
+			if string(content) != expected {
+				t.Errorf("%s: read %q, expected %q", name, content, expected)
+			}
+
+			// reading again stays at EOF instead of starting over
+			for i := 0; i < 2; i++ {
+				if n, err := f.Read(buf); n != 0 || err != io.EOF {
+					t.Errorf("%s: Read at EOF = %d, %v, expected 0, EOF", name, n, err)
+				}
+			}
+			f.Close()
+		}
+	})
+}
+
+func TestFileSeek(t *testing.T) {
+	forEachTestBox(t, func(t *testing.T, box *Box) {
+		const name = "sub/b.txt"
+		content := testBoxFiles[name]
+		size := int64(len(content))
+		f := mustOpen(t, box, name)
+		defer f.Close()
+
+		cases := []struct {
+			offset   int64
+			whence   int
+			expected int64
+		}{
+			{5, io.SeekStart, 5},
+			{3, io.SeekCurrent, 8},
+			{-2, io.SeekCurrent, 6},
+			{0, io.SeekEnd, size},
+			{-4, io.SeekEnd, size - 4},
+			{10, io.SeekEnd, size + 10},
+			{0, io.SeekStart, 0},
+		}
+		for _, c := range cases {
+			pos, err := f.Seek(c.offset, c.whence)
+			if err != nil {
+				t.Fatalf("Seek(%d, %d): %v", c.offset, c.whence, err)
+			}
+			if pos != c.expected {
+				t.Fatalf("Seek(%d, %d) = %d, expected %d", c.offset, c.whence, pos, c.expected)
+			}
+		}
+
+		// seeking before the start fails and leaves the offset as is
+		if _, err := f.Seek(12, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Seek(-1, io.SeekStart); err == nil {
+			t.Error("Seek(-1, SeekStart): expected an error")
+		}
+		if _, err := f.Seek(-100, io.SeekEnd); err == nil {
+			t.Error("Seek(-100, SeekEnd): expected an error")
+		}
+		rest, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(rest) != content[12:] {
+			t.Errorf("read %q after failed seeks, expected %q", rest, content[12:])
+		}
+
+		// reading beyond the end returns EOF
+		if _, err := f.Seek(size+5, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := f.Read(make([]byte, 4)); n != 0 || err != io.EOF {
+			t.Errorf("Read beyond end = %d, %v, expected 0, EOF", n, err)
+		}
+
+		// ReadAt doesn't use or move the offset
+		buf := make([]byte, 6)
+		if n, err := f.ReadAt(buf, 5); n != 6 || err != nil || string(buf) != content[5:11] {
+			t.Errorf("ReadAt(5) = %d, %v, %q, expected 6, nil, %q", n, err, buf, content[5:11])
+		}
+		if n, err := f.ReadAt(buf, size-2); n != 2 || err != io.EOF {
+			t.Errorf("ReadAt(size-2) = %d, %v, expected 2, EOF", n, err)
+		}
+	})
+}
+
+func TestDirReaddir(t *testing.T) {
+	expected := []string{"a.txt", "b.txt", "deeper"}
+	forEachTestBox(t, func(t *testing.T, box *Box) {
+		// page through the directory one entry at a time
+		d := mustOpen(t, box, "sub")
+		var names []string
+		for {
+			infos, err := d.Readdir(1)
+			if err == io.EOF {
+				if len(infos) != 0 {
+					t.Errorf("Readdir(1) returned %d entries with EOF", len(infos))
+				}
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(infos) != 1 {
+				t.Fatalf("Readdir(1) returned %d entries", len(infos))
+			}
+			names = append(names, infos[0].Name())
+		}
+		sort.Strings(names)
+		if !equalStrings(names, expected) {
+			t.Errorf("Readdir(1) listed %v, expected %v", names, expected)
+		}
+
+		// nothing remains, reading all returns an empty list without error
+		infos, err := d.Readdir(-1)
+		if len(infos) != 0 || err != nil {
+			t.Errorf("Readdir(-1) at end = %d entries, %v, expected 0, nil", len(infos), err)
+		}
+		d.Close()
+
+		// Readdir and Readdirnames share the directory offset
+		d = mustOpen(t, box, "sub")
+		first, err := d.Readdirnames(2)
+		if err != nil || len(first) != 2 {
+			t.Fatalf("Readdirnames(2) = %v, %v", first, err)
+		}
+		rest, err := d.Readdir(0)
+		if err != nil || len(rest) != 1 {
+			t.Fatalf("Readdir(0) after Readdirnames(2) = %d entries, %v", len(rest), err)
+		}
+		names = append(first, rest[0].Name())
+		sort.Strings(names)
+		if !equalStrings(names, expected) {
+			t.Errorf("listed %v, expected %v", names, expected)
+		}
+		d.Close()
+
+		// entries report base names and the same info as Stat
+		d = mustOpen(t, box, "sub")
+		infos, err = d.Readdir(-1)
+		d.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, info := range infos {
+			f := mustOpen(t, box, "sub/"+info.Name())
+			stat, err := f.Stat()
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat.Name() != info.Name() || stat.IsDir() != info.IsDir() || stat.Size() != info.Size() {
+				t.Errorf("Readdir entry %s (dir: %v, size: %d) doesn't match Stat %s (dir: %v, size: %d)",
+					info.Name(), info.IsDir(), info.Size(), stat.Name(), stat.IsDir(), stat.Size())
+			}
+		}
+
+		// listing a directory doesn't change the names of the files in it
+		d = mustOpen(t, box, "sub/deeper")
+		d.Readdir(-1)
+		d.Close()
+		if content, err := box.String("sub/deeper/c.txt"); err != nil || content != testBoxFiles["sub/deeper/c.txt"] {
+			t.Errorf("String after Readdir = %q, %v", content, err)
+		}
+	})
+}
+
+func TestFileMisuse(t *testing.T) {
+	forEachTestBox(t, func(t *testing.T, box *Box) {
+		// reading a directory
+		d := mustOpen(t, box, "sub")
+		if _, err := d.Read(make([]byte, 10)); err == nil {
+			t.Error("Read on directory: expected an error")
+		}
+		d.Close()
+
+		// listing a file
+		f := mustOpen(t, box, "file.txt")
+		if _, err := f.Readdir(-1); err == nil {
+			t.Error("Readdir on file: expected an error")
+		}
+		if _, err := f.Readdirnames(-1); err == nil {
+			t.Error("Readdirnames on file: expected an error")
+		}
+		f.Close()
+
+		// opening files that don't exist
+		for _, name := range []string{"missing.txt", "sub/missing", "file.txt/child"} {
+			_, err := box.Open(name)
+			if err == nil {
+				t.Errorf("Open(%q): expected an error", name)
+				continue
+			}
+			if _, ok := err.(*os.PathError); !ok {
+				t.Errorf("Open(%q): expected *os.PathError, got %T", name, err)
+			}
+		}
+		if _, err := box.Open("missing.txt"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Open(missing.txt): expected os.ErrNotExist, got %v", err)
+		}
+	})
+}
+
+func TestFileClosed(t *testing.T) {
+	forEachTestBox(t, func(t *testing.T, box *Box) {
+		for _, name := range []string{"file.txt", "sub"} {
+			f := mustOpen(t, box, name)
+			if err := f.Close(); err != nil {
+				t.Fatalf("%s: Close: %v", name, err)
+			}
+
+			checks := map[string]error{}
+			_, checks["Read"] = f.Read(make([]byte, 1))
+			_, checks["ReadAt"] = f.ReadAt(make([]byte, 1), 0)
+			_, checks["Seek"] = f.Seek(0, io.SeekStart)
+			_, checks["Stat"] = f.Stat()
+			checks["Close"] = f.Close()
+			for op, err := range checks {
+				if !errors.Is(err, os.ErrClosed) {
+					t.Errorf("%s: %s on closed file: expected os.ErrClosed, got %v", name, op, err)
+				}
+			}
+
+			// *os.File doesn't report os.ErrClosed for these
+			if _, err := f.Readdir(-1); err == nil {
+				t.Errorf("%s: Readdir on closed file: expected an error", name)
+			}
+			if _, err := f.Readdirnames(-1); err == nil {
+				t.Errorf("%s: Readdirnames on closed file: expected an error", name)
+			}
+		}
+	})
+}
+
+func TestPathNormalization(t *testing.T) {
+	forEachTestBox(t, func(t *testing.T, box *Box) {
+		expected := testBoxFiles["sub/a.txt"]
+		for _, name := range []string{"sub/a.txt", "/sub/a.txt", "//sub/a.txt", "sub//a.txt", "sub/./a.txt", "sub/deeper/../a.txt"} {
+			content, err := box.String(name)
+			if err != nil {
+				t.Errorf("String(%q): %v", name, err)
+				continue
+			}
+			if content != expected {
+				t.Errorf("String(%q) = %q, expected %q", name, content, expected)
+			}
+		}
+
+		for _, name := range []string{"", "/", ".", "sub/.."} {
+			d, err := box.Open(name)
+			if err != nil {
+				t.Errorf("Open(%q): %v", name, err)
+				continue
+			}
+			info, err := d.Stat()
+			d.Close()
+			if err != nil || !info.IsDir() {
+				t.Errorf("Open(%q) is not the box root: %v", name, err)
+			}
+		}
+
+		if _, err := box.String("sub"); err == nil {
+			t.Error("String on a directory: expected an error")
+		}
+		if _, err := box.String("missing.txt"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("String(missing.txt): expected os.ErrNotExist, got %v", err)
+		}
+	})
+}
+
+func TestPathEscape(t *testing.T) {
+	forEachTestBox(t, func(t *testing.T, box *Box) {
+		for _, name := range []string{"..", "../", "/../file.txt", "../testbox/file.txt", "sub/../../file.txt", "sub/deeper/../../../etc/passwd"} {
+			_, err := box.Open(name)
+			if !errors.Is(err, ErrPathEscape) {
+				t.Errorf("Open(%q): expected ErrPathEscape, got %v", name, err)
+			}
+			if _, ok := err.(*os.PathError); !ok {
+				t.Errorf("Open(%q): expected *os.PathError, got %T", name, err)
+			}
+			if _, err := box.String(name); !errors.Is(err, ErrPathEscape) {
+				t.Errorf("String(%q): expected ErrPathEscape, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestDenySymlinkEscape(t *testing.T) {
+	box := newFSTestBox(t)
+	outside := newFSTestBox(t)
+	if err := os.Symlink(outside.absolutePath, filepath.Join(box.absolutePath, "outside")); err != nil {
+		t.Skipf("unable to create symlink: %v", err)
+	}
+	if err := os.Symlink("sub", filepath.Join(box.absolutePath, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	// symlinks are followed by default
+	if _, err := box.String("outside/file.txt"); err != nil {
+		t.Fatalf("expected symlink to be followed, got %v", err)
+	}
+
+	box.denySymlinkEscape = true
+	if _, err := box.Open("outside/file.txt"); !errors.Is(err, ErrPathEscape) {
+		t.Errorf("expected ErrPathEscape for symlink out of the box, got %v", err)
+	}
+	if _, err := box.Open("outside"); !errors.Is(err, ErrPathEscape) {
+		t.Errorf("expected ErrPathEscape for symlink out of the box, got %v", err)
+	}
+	if _, err := box.String("inside/a.txt"); err != nil {
+		t.Errorf("expected symlink within the box to be followed, got %v", err)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
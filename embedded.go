@@ -2,9 +2,13 @@ package rice
 
 import (
 	"os"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/encryption"
 )
 
 // re-type to make exported methods invisible to user (godoc)
@@ -15,7 +19,7 @@ type embeddedDirInfo embedded.EmbeddedDir
 // Name returns the base name of the directory
 // (implementing os.FileInfo)
 func (ed *embeddedDirInfo) Name() string {
-	return ed.Filename
+	return path.Base(ed.Filename)
 }
 
 // Size always returns 0
@@ -56,12 +60,18 @@ type embeddedFileInfo embedded.EmbeddedFile
 // Name returns the base name of the file
 // (implementing os.FileInfo)
 func (ef *embeddedFileInfo) Name() string {
-	return ef.Filename
+	return path.Base(ef.Filename)
 }
 
 // Size returns the length in bytes for regular files; system-dependent for others
 // (implementing os.FileInfo)
 func (ef *embeddedFileInfo) Size() int64 {
+	if ef.Compressed {
+		return ef.UncompressedSize
+	}
+	if ef.Encrypted {
+		return int64(len(ef.Content) - encryption.Overhead)
+	}
 	return int64(len(ef.Content))
 }
 
@@ -88,3 +98,27 @@ func (ef *embeddedFileInfo) IsDir() bool {
 func (ef *embeddedFileInfo) Sys() interface{} {
 	return nil
 }
+
+// newEmbeddedVirtualFile creates a virtualFile to read the given embedded file,
+// which is not encrypted. Compressed files are decompressed.
+func newEmbeddedVirtualFile(ef *embedded.EmbeddedFile) (*virtualFile, error) {
+	uncompressed, err := ef.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	content := strings.NewReader(uncompressed)
+	return newVirtualFile(ef.Filename, (*embeddedFileInfo)(ef), content, content.Size()), nil
+}
+
+// newEmbeddedVirtualDir creates a virtualDir to list the given embedded directory
+func newEmbeddedVirtualDir(ed *embedded.EmbeddedDir) *virtualDir {
+	entries := make([]os.FileInfo, 0, len(ed.ChildDirs)+len(ed.ChildFiles))
+	for _, child := range ed.ChildDirs {
+		entries = append(entries, (*embeddedDirInfo)(child))
+	}
+	for _, child := range ed.ChildFiles {
+		entries = append(entries, (*embeddedFileInfo)(child))
+	}
+	sort.Sort(SortByName(entries))
+	return newVirtualDir(ed.Filename, (*embeddedDirInfo)(ed), entries)
+}
@@ -2,9 +2,13 @@
 package embedded
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,6 +19,7 @@ const (
 // EmbeddedBox defines an embedded box
 type EmbeddedBox struct {
 	Name      string                   // box name
+	Package   string                   // import path of the package that uses the box ("main" for commands), empty for boxes embedded by older versions of rice
 	Time      time.Time                // embed time
 	EmbedType int                      // kind of embedding
 	Files     map[string]*EmbeddedFile // ALL embedded files by full path
@@ -65,18 +70,65 @@ type EmbeddedDir struct {
 
 // EmbeddedFile is instanced in the code generated by the rice tool and contains all necicary information about an embedded file
 type EmbeddedFile struct {
-	Filename    string // filename
-	FileModTime time.Time
-	Content     string
+	Filename         string // filename
+	FileModTime      time.Time
+	Content          string
+	Encrypted        bool   // Content is encrypted, it is decrypted with the key from rice.Config when opened
+	Compressed       bool   // Content is gzip compressed (before it was encrypted)
+	UncompressedSize int64  // size of the original file, when Compressed
+	Hash             string // hex encoded SHA-256 of the original file, empty for encrypted files and files embedded by older versions of rice
+
+	uncompressOnce sync.Once
+	uncompressed   string
+	uncompressErr  error
+}
+
+// Uncompressed returns the content of a file that is not encrypted. When the
+// content is compressed, it is decompressed on the first call and kept for
+// the next calls.
+func (ef *EmbeddedFile) Uncompressed() (string, error) {
+	if !ef.Compressed {
+		return ef.Content, nil
+	}
+	ef.uncompressOnce.Do(func() {
+		var content []byte
+		content, ef.uncompressErr = Gunzip([]byte(ef.Content))
+		ef.uncompressed = string(content)
+	})
+	return ef.uncompressed, ef.uncompressErr
 }
 
-// EmbeddedBoxes is a public register of embedded boxes
+// Gunzip decompresses gzip compressed content.
+func Gunzip(compressed []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	return content, zr.Close()
+}
+
+// EmbeddedBoxes is a public register of embedded boxes, keyed by BoxKey
 var EmbeddedBoxes = make(map[string]*EmbeddedBox)
 
-// RegisterEmbeddedBox registers an EmbeddedBox
+// BoxKey returns the key for a box in a register of boxes. Boxes are
+// namespaced by the import path of the package that uses them, so packages
+// can use boxes with the same name. A box without namespace is keyed by name.
+func BoxKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + ":" + name
+}
+
+// RegisterEmbeddedBox registers an EmbeddedBox under its Package and given name
 func RegisterEmbeddedBox(name string, box *EmbeddedBox) {
-	if _, exists := EmbeddedBoxes[name]; exists {
-		panic(fmt.Sprintf("EmbeddedBox with name `%s` exists already", name))
+	key := BoxKey(box.Package, name)
+	if _, exists := EmbeddedBoxes[key]; exists {
+		panic(fmt.Sprintf("EmbeddedBox with name `%s` exists already", key))
 	}
-	EmbeddedBoxes[name] = box
+	EmbeddedBoxes[key] = box
 }
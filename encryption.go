@@ -0,0 +1,81 @@
+package rice
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/encryption"
+)
+
+var (
+	// ErrNoDecryptionKey is the error (wrapped in an *os.PathError) that is
+	// returned when opening an encrypted file from a box that was found
+	// without a decryption key, see Config.DecryptionKey.
+	ErrNoDecryptionKey = errors.New("file is encrypted and no decryption key is configured")
+
+	// ErrDecryptionFailed is the error (wrapped in an *os.PathError) that is
+	// returned when an encrypted file can't be decrypted, because the key is
+	// wrong or the file was modified.
+	ErrDecryptionFailed = errors.New("file can't be decrypted with the configured key")
+)
+
+// getDecryptionKey returns the key to decrypt the files in the box. A key
+// from Config.DecryptionKeyFunc is kept for the next files.
+func (b *Box) getDecryptionKey() ([]byte, error) {
+	b.decryptionKeyMu.Lock()
+	defer b.decryptionKeyMu.Unlock()
+
+	if b.decryptionKey != nil {
+		return b.decryptionKey, nil
+	}
+	if b.decryptionKeyFunc == nil {
+		return nil, ErrNoDecryptionKey
+	}
+	key, err := b.decryptionKeyFunc()
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, ErrNoDecryptionKey
+	}
+	b.decryptionKey = key
+	return key, nil
+}
+
+// openEncrypted decrypts an encrypted file, and returns a File to read the
+// decrypted content. The file is decrypted as a whole, and decompressed when
+// it was compressed before encryption.
+func (b *Box) openEncrypted(name string, info os.FileInfo, encrypted []byte, compressed bool) (*File, error) {
+	key, err := b.getDecryptionKey()
+	if err != nil {
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: name,
+			Err:  err,
+		}
+	}
+	content, err := encryption.Decrypt(key, name, encrypted)
+	if err != nil {
+		if err == encryption.ErrDecrypt {
+			err = ErrDecryptionFailed
+		}
+		return nil, &os.PathError{
+			Op:   "open",
+			Path: name,
+			Err:  err,
+		}
+	}
+	if compressed {
+		content, err = embedded.Gunzip(content)
+		if err != nil {
+			return nil, &os.PathError{
+				Op:   "open",
+				Path: name,
+				Err:  err,
+			}
+		}
+	}
+	return &File{virtualF: newVirtualFile(name, info, bytes.NewReader(content), int64(len(content)))}, nil
+}
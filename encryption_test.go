@@ -0,0 +1,171 @@
+package rice
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/archive"
+	"github.com/GeertJohan/go.rice/internal/encryption"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte("k"), 32)
+
+// newEncryptedTestBoxes creates an embedded and an appended box that hold an
+// encrypted file "secret.txt" with the given content.
+func newEncryptedTestBoxes(t *testing.T, content string) []*Box {
+	encrypted, err := encryption.Encrypt(testEncryptionKey, "secret.txt", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eb := &embedded.EmbeddedBox{
+		Name: "encrypted",
+		Files: map[string]*embedded.EmbeddedFile{
+			"secret.txt": {Filename: "secret.txt", Content: string(encrypted), Encrypted: true},
+		},
+		Dirs: map[string]*embedded.EmbeddedDir{
+			"": {Filename: ""},
+		},
+	}
+	eb.Link()
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	if _, err := zw.CreateHeader(&zip.FileHeader{Name: "encrypted", Comment: archive.DirAttr}); err != nil {
+		t.Fatal(err)
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "encrypted/secret.txt", Comment: archive.EncryptedAttr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(encrypted)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ra := bytes.NewReader(buf.Bytes())
+	rd, err := zip.NewReader(ra, ra.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes, errs := loadAppendedBoxes(ra, ra.Size(), rd)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	return []*Box{
+		{name: "encrypted", embed: eb},
+		{name: "encrypted", appendd: boxes["encrypted"]},
+	}
+}
+
+func TestEncryptedBox(t *testing.T) {
+	const content = "licensed content"
+	for _, box := range newEncryptedTestBoxes(t, content) {
+		box := box
+		configure := func(cfg *Config) {
+			box.decryptionKeyFunc = cfg.decryptionKeyFunc()
+			box.decryptionKey = nil
+		}
+
+		// without a key
+		if _, err := box.Open("secret.txt"); !errors.Is(err, ErrNoDecryptionKey) {
+			t.Errorf("open without key: expected ErrNoDecryptionKey, got %v", err)
+		}
+		if _, err := box.String("secret.txt"); !errors.Is(err, ErrNoDecryptionKey) {
+			t.Errorf("String without key: expected ErrNoDecryptionKey, got %v", err)
+		}
+		// listing the box doesn't need the key
+		var walked []string
+		err := box.Walk("", func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			walked = append(walked, path)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Walk without key: %v", err)
+		}
+		if !equalStrings(walked, []string{"", "secret.txt"}) {
+			t.Errorf("Walk visited %v", walked)
+		}
+		if info, err := fs.Stat(box.FS(), "secret.txt"); err != nil {
+			t.Errorf("fs.Stat without key: %v", err)
+		} else if info.Size() != int64(len(content)) {
+			t.Errorf("fs.Stat size = %d, expected %d", info.Size(), len(content))
+		}
+		if _, err := fs.Stat(box.FS(), "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("fs.Stat of a missing file: expected fs.ErrNotExist, got %v", err)
+		} else if pathErr, ok := err.(*fs.PathError); !ok || pathErr.Op != "stat" || pathErr.Path != "missing.txt" {
+			t.Errorf("fs.Stat of a missing file: unexpected error %#v", err)
+		}
+
+		// with the wrong key
+		configure(&Config{DecryptionKey: bytes.Repeat([]byte("x"), 32)})
+		if _, err := box.Open("secret.txt"); !errors.Is(err, ErrDecryptionFailed) {
+			t.Errorf("open with wrong key: expected ErrDecryptionFailed, got %v", err)
+		}
+
+		// with a failing key func, which is called again on the next open
+		calls := 0
+		configure(&Config{DecryptionKeyFunc: func() ([]byte, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("key service unavailable")
+			}
+			return testEncryptionKey, nil
+		}})
+		if _, err := box.Open("secret.txt"); err == nil {
+			t.Error("expected the error of the key func")
+		}
+		for i := 0; i < 2; i++ {
+			s, err := box.String("secret.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != content {
+				t.Errorf("read %q, expected %q", s, content)
+			}
+		}
+		if calls != 2 {
+			t.Errorf("key func was called %d times, expected 2", calls)
+		}
+
+		// the size of the decrypted content is reported
+		f, err := box.Open("secret.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(content)) {
+			t.Errorf("Stat().Size() = %d, expected %d", info.Size(), len(content))
+		}
+		f.Close()
+		d, err := box.Open("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos, err := d.Readdir(-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(infos) != 1 || infos[0].Size() != int64(len(content)) {
+			t.Errorf("Readdir returned %v, expected secret.txt of %d bytes", infos, len(content))
+		}
+		d.Close()
+
+		read, err := ioutil.ReadAll(mustOpen(t, box, "secret.txt"))
+		if err != nil || string(read) != content {
+			t.Errorf("read %q, %v", read, err)
+		}
+	}
+}
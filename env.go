@@ -0,0 +1,117 @@
+package rice
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables that change where boxes are located, without
+// rebuilding the program.
+const (
+	// EnvBoxPrefix followed by the sanitized name of a box (see EnvBoxName)
+	// holds the directory that LocateEnv opens the box from, e.g.
+	// RICE_BOX_TEMPLATES=/srv/hotfix/templates.
+	EnvBoxPrefix = "RICE_BOX_"
+
+	// EnvBoxOverrides holds a JSON object with the directories that
+	// LocateEnv opens boxes from, keyed by box name, e.g.
+	// {"templates": "/srv/hotfix/templates"}. Variables with EnvBoxPrefix
+	// take precedence.
+	EnvBoxOverrides = "RICE_BOX_OVERRIDES"
+
+	// EnvLocateOrder replaces the default LocateOrder of FindBox and
+	// MustFindBox with a comma separated list of locate methods, e.g.
+	// "env,fs,embedded". Custom locators are referred to by the name they
+	// were registered with. The LocateOrder of a Config is never replaced.
+	EnvLocateOrder = "RICE_LOCATE_ORDER"
+)
+
+// EnvBoxName returns the environment variable that overrides the directory
+// of the box with the given name: EnvBoxPrefix followed by the name in upper
+// case, with every character other than letters and digits replaced by an
+// underscore, e.g. RICE_BOX_ASSETS_CSS for "assets/css".
+func EnvBoxName(name string) string {
+	return EnvBoxPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// envOverride returns the directory that the environment sets for the box,
+// and the variable that sets it
+func envOverride(name string) (dir, variable string, err error) {
+	variable = EnvBoxName(name)
+	if dir := os.Getenv(variable); dir != "" {
+		return dir, variable, nil
+	}
+	overrides := os.Getenv(EnvBoxOverrides)
+	if overrides == "" {
+		return "", "", nil
+	}
+	var dirs map[string]string
+	if err := json.Unmarshal([]byte(overrides), &dirs); err != nil {
+		return "", EnvBoxOverrides, fmt.Errorf("invalid %s: %w", EnvBoxOverrides, err)
+	}
+	return dirs[name], EnvBoxOverrides, nil
+}
+
+// envLocator implements LocateEnv. Anyone who can set the environment of the
+// process can replace a box with it, so it refuses to locate boxes for a
+// config that only accepts signed boxes.
+type envLocator struct{}
+
+func (envLocator) Locate(req *LocateRequest) (*Box, error) {
+	if req.Config.AppendedPublicKey != nil {
+		return nil, fmt.Errorf("%w, overrides in the environment are not signed and AppendedPublicKey is set", ErrBoxNotFound)
+	}
+	dir, variable, err := envOverride(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return nil, fmt.Errorf("%w, %s and %s don't set it", ErrBoxNotFound, EnvBoxName(req.Name), EnvBoxOverrides)
+	}
+	// the box on disk refers to its files by absolute path
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	b, err := req.DirBox(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", variable, err)
+	}
+	if Debug {
+		fmt.Printf("Box %q is overridden by %s, using %s\n", req.Name, variable, dir)
+	}
+	return b, nil
+}
+
+// defaultLocateOrderFromEnv returns the LocateOrder of FindBox and
+// MustFindBox, which EnvLocateOrder overrides
+func defaultLocateOrderFromEnv() ([]LocateMethod, error) {
+	names := os.Getenv(EnvLocateOrder)
+	if names == "" {
+		return defaultLocateOrder, nil
+	}
+	var order []LocateMethod
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		method, ok := lookupLocateMethod(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s: no such locate method %q", EnvLocateOrder, name)
+		}
+		order = append(order, method)
+	}
+	return order, nil
+}
@@ -0,0 +1,120 @@
+package rice
+
+import (
+	"crypto/ed25519"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setenv sets an environment variable for the duration of the test
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestEnvBoxName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"templates":       "RICE_BOX_TEMPLATES",
+		"assets/css":      "RICE_BOX_ASSETS_CSS",
+		"../shared/v2-ui": "RICE_BOX____SHARED_V2_UI",
+		"Mixed.Case":      "RICE_BOX_MIXED_CASE",
+	} {
+		if variable := EnvBoxName(name); variable != expected {
+			t.Errorf("EnvBoxName(%q) = %q, expected %q", name, variable, expected)
+		}
+	}
+}
+
+func TestLocateEnv(t *testing.T) {
+	hotfix := t.TempDir()
+	setenv(t, "RICE_BOX_BOX1", hotfix)
+	setenv(t, EnvBoxOverrides, `{"box1": "/not/used", "box2": "`+fsb3+`"}`)
+
+	// overrides are only used when LocateEnv is in the LocateOrder
+	b, err := FindBox("box1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.embed != eb1 {
+		t.Errorf("expected the embedded box, got %#v", b)
+	}
+
+	cfg := Config{LocateOrder: []LocateMethod{LocateEnv, LocateEmbedded}}
+	b, err = cfg.FindBox("box1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.absolutePath != hotfix {
+		t.Errorf("expected the box of RICE_BOX_BOX1, got %#v", b)
+	}
+	b, err = cfg.FindBox("box2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.absolutePath != fsb3 {
+		t.Errorf("expected the box of %s, got %#v", EnvBoxOverrides, b)
+	}
+
+	// overrides aren't signed, so they never replace boxes of a config that
+	// requires signatures
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := Config{LocateOrder: []LocateMethod{LocateEnv, LocateEmbedded}, AppendedPublicKey: pub}
+	if b, err := signed.FindBox("box1"); err != nil || b.embed != eb1 {
+		t.Errorf("expected the embedded box, got %#v, %v", b, err)
+	}
+
+	// the next method is tried when the override doesn't work
+	setenv(t, "RICE_BOX_BOX1", hotfix+"/missing")
+	b, err = cfg.FindBox("box1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.embed != eb1 {
+		t.Errorf("expected the embedded box, got %#v", b)
+	}
+
+	setenv(t, EnvBoxOverrides, `{"box2": `)
+	cfg.LocateOrder = []LocateMethod{LocateEnv}
+	if _, err := cfg.FindBox("box2"); err == nil || !strings.Contains(err.Error(), "invalid "+EnvBoxOverrides) {
+		t.Errorf("expected an error for invalid JSON, got %v", err)
+	}
+}
+
+func TestEnvLocateOrder(t *testing.T) {
+	setenv(t, EnvLocateOrder, "fs, embedded")
+	b, err := FindBox("box1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.absolutePath != fsb1 {
+		t.Errorf("expected the box on disk, got %#v", b)
+	}
+
+	// custom locators are referred to by name
+	setenv(t, EnvLocateOrder, "fixture")
+	b, err = FindBox("box1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.embed != fixtureBox {
+		t.Errorf("expected the fixture, got %#v", b)
+	}
+
+	setenv(t, EnvLocateOrder, "embedded,nosuchmethod")
+	if _, err := FindBox("box1"); err == nil || !strings.Contains(err.Error(), `no such locate method "nosuchmethod"`) {
+		t.Errorf("expected an error for an unknown method, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package rice_test
+
+import (
+	"testing"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/GeertJohan/go.rice/embedded"
+)
+
+func init() {
+	// registered like rice embed-go does for the package under test
+	embedded.RegisterEmbeddedBox("external", &embedded.EmbeddedBox{
+		Name:    "external",
+		Package: "github.com/GeertJohan/go.rice",
+		Files:   map[string]*embedded.EmbeddedFile{"file.txt": {Filename: "file.txt", Content: "external"}},
+		Dirs:    map[string]*embedded.EmbeddedDir{"": {Filename: ""}},
+	})
+}
+
+func TestFindBoxFromExternalTestPackage(t *testing.T) {
+	cfg := rice.Config{LocateOrder: []rice.LocateMethod{rice.LocateEmbedded}}
+	box, err := cfg.FindBox("external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := box.String("file.txt"); err != nil || s != "external" {
+		t.Errorf("expected the box of the package under test, got %q, %v", s, err)
+	}
+}
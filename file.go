@@ -1,40 +1,25 @@
 package rice
 
 import (
-	"bytes"
-	"errors"
+	"io/fs"
 	"os"
-	"path/filepath"
 )
 
-// File implements the io.Reader, io.Seeker, io.Closer and http.File interfaces
+// File implements the io.Reader, io.ReaderAt, io.Seeker, io.Closer and http.File interfaces
 type File struct {
 	// File abstracts file methods so the user doesn't see the difference between rice.virtualFile, rice.virtualDir and os.File
-	// TODO: maybe use internal File interface and four implementations: *os.File, appendedFile, virtualFile, virtualDir
 
 	// real file on disk
 	realF *os.File
 
-	// when embedded (go)
+	// when embedded (go) or appended (zip)
 	virtualF *virtualFile
 	virtualD *virtualDir
-
-	// when appended (zip)
-	appendedF          *appendedFile
-	appendedFileReader *bytes.Reader
-	// TODO: is appendedFileReader subject of races? Might need a lock here..
 }
 
 // Close is like (*os.File).Close()
 // Visit http://golang.org/pkg/os/#File.Close for more information
 func (f *File) Close() error {
-	if f.appendedF != nil {
-		if f.appendedFileReader == nil {
-			return errors.New("already closed")
-		}
-		f.appendedFileReader = nil
-		return nil
-	}
 	if f.virtualF != nil {
 		return f.virtualF.close()
 	}
@@ -47,15 +32,6 @@ func (f *File) Close() error {
 // Stat is like (*os.File).Stat()
 // Visit http://golang.org/pkg/os/#File.Stat for more information
 func (f *File) Stat() (os.FileInfo, error) {
-	if f.appendedF != nil {
-		if f.appendedF.dir {
-			return f.appendedF.dirInfo, nil
-		}
-		if f.appendedFileReader == nil {
-			return nil, errors.New("file is closed")
-		}
-		return f.appendedF.zipFile.FileInfo(), nil
-	}
 	if f.virtualF != nil {
 		return f.virtualF.stat()
 	}
@@ -68,21 +44,6 @@ func (f *File) Stat() (os.FileInfo, error) {
 // Readdir is like (*os.File).Readdir()
 // Visit http://golang.org/pkg/os/#File.Readdir for more information
 func (f *File) Readdir(count int) ([]os.FileInfo, error) {
-	if f.appendedF != nil {
-		if f.appendedF.dir {
-			fi := make([]os.FileInfo, 0, len(f.appendedF.children))
-			for _, childAppendedFile := range f.appendedF.children {
-				if childAppendedFile.dir {
-					fi = append(fi, childAppendedFile.dirInfo)
-				} else {
-					fi = append(fi, childAppendedFile.zipFile.FileInfo())
-				}
-			}
-			return fi, nil
-		}
-		//++ TODO: is os.ErrInvalid the correct error for Readdir on file?
-		return nil, os.ErrInvalid
-	}
 	if f.virtualF != nil {
 		return f.virtualF.readdir(count)
 	}
@@ -95,21 +56,6 @@ func (f *File) Readdir(count int) ([]os.FileInfo, error) {
 // Readdirnames is like (*os.File).Readdirnames()
 // Visit http://golang.org/pkg/os/#File.Readdirnames for more information
 func (f *File) Readdirnames(count int) ([]string, error) {
-	if f.appendedF != nil {
-		if f.appendedF.dir {
-			names := make([]string, 0, len(f.appendedF.children))
-			for _, childAppendedFile := range f.appendedF.children {
-				if childAppendedFile.dir {
-					names = append(names, childAppendedFile.dirInfo.name)
-				} else {
-					names = append(names, childAppendedFile.zipFile.FileInfo().Name())
-				}
-			}
-			return names, nil
-		}
-		// os.ErrInvalid to match the os.SyscallError (readdirent: invalid argument) that os.File returns
-		return nil, os.ErrInvalid
-	}
 	if f.virtualF != nil {
 		return f.virtualF.readdirnames(count)
 	}
@@ -119,26 +65,33 @@ func (f *File) Readdirnames(count int) ([]string, error) {
 	return f.realF.Readdirnames(count)
 }
 
+// ReadDir is like (*os.File).ReadDir()
+// Visit https://golang.org/pkg/os/#File.ReadDir for more information
+func (f *File) ReadDir(count int) ([]fs.DirEntry, error) {
+	if f.realF != nil {
+		return f.realF.ReadDir(count)
+	}
+	infos, err := f.Readdir(count)
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, dirEntry{info})
+	}
+	return entries, err
+}
+
+// dirEntry is an fs.DirEntry for the os.FileInfo of a virtual file or dir
+type dirEntry struct {
+	info os.FileInfo
+}
+
+func (d dirEntry) Name() string               { return d.info.Name() }
+func (d dirEntry) IsDir() bool                { return d.info.IsDir() }
+func (d dirEntry) Type() fs.FileMode          { return d.info.Mode().Type() }
+func (d dirEntry) Info() (fs.FileInfo, error) { return d.info, nil }
+
 // Read is like (*os.File).Read()
 // Visit http://golang.org/pkg/os/#File.Read for more information
 func (f *File) Read(bts []byte) (int, error) {
-	if f.appendedF != nil {
-		if f.appendedFileReader == nil {
-			return 0, &os.PathError{
-				Op:   "read",
-				Path: filepath.Base(f.appendedF.zipFile.Name),
-				Err:  errors.New("file is closed"),
-			}
-		}
-		if f.appendedF.dir {
-			return 0, &os.PathError{
-				Op:   "read",
-				Path: filepath.Base(f.appendedF.zipFile.Name),
-				Err:  errors.New("is a directory"),
-			}
-		}
-		return f.appendedFileReader.Read(bts)
-	}
 	if f.virtualF != nil {
 		return f.virtualF.read(bts)
 	}
@@ -148,19 +101,21 @@ func (f *File) Read(bts []byte) (int, error) {
 	return f.realF.Read(bts)
 }
 
+// ReadAt is like (*os.File).ReadAt()
+// Visit http://golang.org/pkg/os/#File.ReadAt for more information
+func (f *File) ReadAt(bts []byte, offset int64) (int, error) {
+	if f.virtualF != nil {
+		return f.virtualF.readAt(bts, offset)
+	}
+	if f.virtualD != nil {
+		return f.virtualD.readAt(bts, offset)
+	}
+	return f.realF.ReadAt(bts, offset)
+}
+
 // Seek is like (*os.File).Seek()
 // Visit http://golang.org/pkg/os/#File.Seek for more information
 func (f *File) Seek(offset int64, whence int) (int64, error) {
-	if f.appendedF != nil {
-		if f.appendedFileReader == nil {
-			return 0, &os.PathError{
-				Op:   "seek",
-				Path: filepath.Base(f.appendedF.zipFile.Name),
-				Err:  errors.New("file is closed"),
-			}
-		}
-		return f.appendedFileReader.Seek(offset, whence)
-	}
 	if f.virtualF != nil {
 		return f.virtualF.seek(offset, whence)
 	}
@@ -0,0 +1,152 @@
+package rice
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// fingerprintLength is the number of hex digits of the hash in a fingerprinted name
+const fingerprintLength = 16
+
+// Fingerprint returns the fingerprinted name of the file with the given name:
+// the name with the start of the hash of its content (see Hash) before the
+// extension, e.g. "js/app.3f2a9c1b5e7d4a60.js" for "js/app.js". The
+// fingerprinted name changes when the content changes, so clients can cache
+// it forever. An HTTPBox with Fingerprinting set serves files under their
+// fingerprinted names.
+//
+// The hash is recorded by the rice tool for embedded and appended boxes, so
+// the file is not read (except when it is encrypted).
+// If there is an error, it will be of type *os.PathError.
+func (b *Box) Fingerprint(name string) (string, error) {
+	name, err := cleanName("fingerprint", name)
+	if err != nil {
+		return "", err
+	}
+	hash, err := b.Hash(name)
+	if err != nil {
+		return "", err
+	}
+	return fingerprintName(name, hash), nil
+}
+
+// Integrity returns the Subresource Integrity value of the file with the
+// given name, e.g. "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+// for the integrity attribute of script and link elements.
+// If there is an error, it will be of type *os.PathError.
+func (b *Box) Integrity(name string) (string, error) {
+	hash, err := b.Hash(name)
+	if err != nil {
+		return "", err
+	}
+	sum, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", &os.PathError{Op: "integrity", Path: name, Err: err}
+	}
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// fingerprintName inserts the start of the hash into the name, before the extension
+func fingerprintName(name, hash string) string {
+	if len(hash) > fingerprintLength {
+		hash = hash[:fingerprintLength]
+	}
+	ext := path.Ext(name)
+	return name[:len(name)-len(ext)] + "." + hash + ext
+}
+
+// splitFingerprint splits a fingerprinted name into the name of the file and
+// the fingerprint, e.g. "js/app.3f2a9c1b5e7d4a60.js" into "js/app.js" and
+// "3f2a9c1b5e7d4a60". It returns false when the name isn't fingerprinted.
+func splitFingerprint(fingerprinted string) (name, fingerprint string, ok bool) {
+	dir, base := path.Split(fingerprinted)
+	ext := path.Ext(base)
+	stem := base[:len(base)-len(ext)]
+	// a name without extension, e.g. LICENSE.3f2a9c1b5e7d4a60
+	if isFingerprint(ext) && stem != "" {
+		return dir + stem, ext[1:], true
+	}
+	fp := path.Ext(stem)
+	if isFingerprint(fp) && len(stem) > len(fp) {
+		return dir + stem[:len(stem)-len(fp)] + ext, fp[1:], true
+	}
+	return "", "", false
+}
+
+// isFingerprint returns whether ext (including the dot) is a fingerprint
+func isFingerprint(ext string) bool {
+	if len(ext) != fingerprintLength+1 {
+		return false
+	}
+	for _, c := range ext[1:] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Manifest maps the names of the files in a box to their fingerprinted names
+// and Subresource Integrity values. It marshals to JSON, for use by other
+// tools.
+type Manifest map[string]ManifestEntry
+
+// ManifestEntry describes a file in a Manifest
+type ManifestEntry struct {
+	URL       string `json:"url"`       // fingerprinted name of the file, see Box.Fingerprint
+	Integrity string `json:"integrity"` // Subresource Integrity value, see Box.Integrity
+}
+
+// Manifest returns the manifest of all files in the box. For embedded and
+// appended boxes it is built from the hashes recorded by the rice tool.
+func (b *Box) Manifest() (Manifest, error) {
+	manifest := make(Manifest)
+	err := b.Walk("", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name = filepath.ToSlash(name)
+		url, err := b.Fingerprint(name)
+		if err != nil {
+			return err
+		}
+		integrity, err := b.Integrity(name)
+		if err != nil {
+			return err
+		}
+		manifest[name] = ManifestEntry{URL: url, Integrity: integrity}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// AssetURL returns the fingerprinted name of the file with the given name in
+// the box, for URLs that are served by an HTTPBox with Fingerprinting set.
+//
+//	e.g.: <script src="/static/{{assetURL "js/app.js"}}"></script>
+func AssetURL(box *Box, name string) (string, error) {
+	return box.Fingerprint(name)
+}
+
+// AssetFuncs returns template functions for the files in the box, to be
+// added to a text/template or html/template with Funcs:
+//
+//	assetURL "js/app.js"        fingerprinted name of the file, see AssetURL
+//	assetIntegrity "js/app.js"  Subresource Integrity value of the file, see Box.Integrity
+func AssetFuncs(box *Box) map[string]interface{} {
+	return map[string]interface{}{
+		"assetURL": func(name string) (string, error) {
+			return AssetURL(box, name)
+		},
+		"assetIntegrity": box.Integrity,
+	}
+}
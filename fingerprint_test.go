@@ -0,0 +1,153 @@
+package rice
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"html"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplitFingerprint(t *testing.T) {
+	const fp = "0123456789abcdef"
+	cases := []struct {
+		name          string
+		fingerprinted string
+	}{
+		{"app.js", "app." + fp + ".js"},
+		{"js/app.min.js", "js/app.min." + fp + ".js"},
+		{"sub.d/LICENSE", "sub.d/LICENSE." + fp},
+		{"app.js.gz", "app.js." + fp + ".gz"},
+	}
+	for _, c := range cases {
+		if fingerprinted := fingerprintName(c.name, fp+"0123"); fingerprinted != c.fingerprinted {
+			t.Errorf("fingerprintName(%q) = %q, expected %q", c.name, fingerprinted, c.fingerprinted)
+		}
+		name, fingerprint, ok := splitFingerprint(c.fingerprinted)
+		if !ok || name != c.name || fingerprint != fp {
+			t.Errorf("splitFingerprint(%q) = %q, %q, %v", c.fingerprinted, name, fingerprint, ok)
+		}
+	}
+
+	for _, name := range []string{"app.js", "app.0123456789ABCDEF.js", "app.0123456789abcde.js", "." + fp, "." + fp + ".js", ""} {
+		if _, _, ok := splitFingerprint(name); ok {
+			t.Errorf("splitFingerprint(%q): expected no fingerprint", name)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	for method, box := range newTestBoxes(t) {
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			manifest, err := box.Manifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(manifest) != len(testBoxFiles) {
+				t.Errorf("manifest has %d files, expected %d", len(manifest), len(testBoxFiles))
+			}
+			for name, content := range testBoxFiles {
+				hash := sha256.Sum256([]byte(content))
+				expectedURL := fingerprintName(name, sha256Hex(content))
+				expectedIntegrity := "sha256-" + base64.StdEncoding.EncodeToString(hash[:])
+
+				url, err := AssetURL(box, name)
+				if err != nil || url != expectedURL {
+					t.Errorf("AssetURL(%q) = %q, %v, expected %q", name, url, err, expectedURL)
+				}
+				integrity, err := box.Integrity(name)
+				if err != nil || integrity != expectedIntegrity {
+					t.Errorf("Integrity(%q) = %q, %v, expected %q", name, integrity, err, expectedIntegrity)
+				}
+				if entry := manifest[name]; entry.URL != expectedURL || entry.Integrity != expectedIntegrity {
+					t.Errorf("manifest entry of %q is %+v", name, entry)
+				}
+			}
+			if _, err := box.Fingerprint("sub"); err == nil {
+				t.Error("expected an error for the fingerprint of a directory")
+			}
+		})
+	}
+}
+
+func TestAssetFuncs(t *testing.T) {
+	box := newEmbeddedTestBox()
+	tmpl := template.Must(template.New("page").Funcs(AssetFuncs(box)).Parse(
+		`<script src="/static/{{assetURL "sub/a.txt"}}" integrity="{{assetIntegrity "sub/a.txt"}}"></script>`))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	url, _ := box.Fingerprint("sub/a.txt")
+	integrity, _ := box.Integrity("sub/a.txt")
+	expected := `<script src="/static/` + url + `" integrity="` + integrity + `"></script>`
+	// html/template escapes + in attributes as &#43;, browsers unescape it
+	if html.UnescapeString(buf.String()) != expected {
+		t.Errorf("got %s, expected %s", buf.String(), expected)
+	}
+
+	tmpl = template.Must(template.New("missing").Funcs(AssetFuncs(box)).Parse(`{{assetURL "missing.js"}}`))
+	if err := tmpl.Execute(&buf, nil); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestHTTPBoxFingerprinting(t *testing.T) {
+	for method, box := range newTestBoxes(t) {
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			fingerprinted, err := box.Fingerprint("sub/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stale := fingerprintName("sub/a.txt", sha256Hex("old content"))
+
+			cases := []struct {
+				path           string
+				fingerprinting bool
+				status         int
+				cacheControl   string
+			}{
+				{"/" + fingerprinted, true, http.StatusOK, DefaultAssetCacheControl},
+				{"/sub/a.txt", true, http.StatusOK, ""},
+				{"/" + stale, true, http.StatusNotFound, ""},
+				{"/" + fingerprinted + "/", true, http.StatusNotFound, ""},
+				{"/" + fingerprinted, false, http.StatusNotFound, ""},
+			}
+			for _, c := range cases {
+				handler := box.HTTPBox()
+				handler.Fingerprinting = c.fingerprinting
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest("GET", c.path, nil))
+				if rec.Code != c.status {
+					t.Errorf("%s (fingerprinting %v): status %d, expected %d", c.path, c.fingerprinting, rec.Code, c.status)
+					continue
+				}
+				if c.status != http.StatusOK {
+					continue
+				}
+				if rec.Body.String() != testBoxFiles["sub/a.txt"] {
+					t.Errorf("%s: unexpected body %q", c.path, rec.Body.String())
+				}
+				if cc := rec.Header().Get("Cache-Control"); cc != c.cacheControl {
+					t.Errorf("%s: Cache-Control %q, expected %q", c.path, cc, c.cacheControl)
+				}
+				if ctype := rec.Header().Get("Content-Type"); ctype != "text/plain; charset=utf-8" {
+					t.Errorf("%s: Content-Type %q", c.path, ctype)
+				}
+			}
+
+			// the SPA handler serves fingerprinted files as hashed assets
+			spa := box.HTTPBox().SPAHandler()
+			spa.Box.Fingerprinting = true
+			spa.AssetCacheControl = "max-age=60"
+			rec := httptest.NewRecorder()
+			spa.ServeHTTP(rec, httptest.NewRequest("GET", "/"+fingerprinted, nil))
+			if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "max-age=60" {
+				t.Errorf("SPA: status %d, Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
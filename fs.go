@@ -0,0 +1,130 @@
+package rice
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+)
+
+// FSBox implements fs.FS, fs.ReadDirFS, fs.StatFS, fs.ReadFileFS and fs.SubFS,
+// which allows the use of Box with the io/fs based APIs of the standard library.
+//
+//	e.g.: template.ParseFS(rice.MustFindBox("templates").FS(), "*.tmpl")
+type FSBox struct {
+	box *Box
+	dir string // directory within the box that this FSBox is rooted at
+}
+
+// compile time check for the interfaces implemented by FSBox
+var (
+	_ fs.ReadDirFS  = (*FSBox)(nil)
+	_ fs.StatFS     = (*FSBox)(nil)
+	_ fs.ReadFileFS = (*FSBox)(nil)
+	_ fs.SubFS      = (*FSBox)(nil)
+)
+
+// FS creates a new FSBox from an existing Box
+func (b *Box) FS() *FSBox {
+	return &FSBox{box: b}
+}
+
+// resolve validates the given fs.FS name and returns the path within the box.
+func (fb *FSBox) resolve(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{
+			Op:   op,
+			Path: name,
+			Err:  fs.ErrInvalid,
+		}
+	}
+	full := path.Join(fb.dir, name)
+	if full == "." {
+		full = ""
+	}
+	return full, nil
+}
+
+// open opens the named file, errors refer to the name as given to the FSBox.
+func (fb *FSBox) open(op, name string) (*File, error) {
+	full, err := fb.resolve(op, name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fb.box.Open(full)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, &fs.PathError{Op: op, Path: name, Err: pathErr.Err}
+		}
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return f, nil
+}
+
+// Open opens the named file (implementing fs.FS)
+func (fb *FSBox) Open(name string) (fs.File, error) {
+	f, err := fb.open("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat returns a fs.FileInfo describing the named file (implementing fs.StatFS).
+// The file is not opened, so encrypted files are not decrypted.
+func (fb *FSBox) Stat(name string) (fs.FileInfo, error) {
+	full, err := fb.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fb.box.stat(full)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: pathErr.Err}
+		}
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// ReadFile reads the named file and returns its contents (implementing fs.ReadFileFS)
+func (fb *FSBox) ReadFile(name string) ([]byte, error) {
+	f, err := fb.open("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+// ReadDir reads the named directory and returns a list of directory entries
+// sorted by filename (implementing fs.ReadDirFS)
+func (fb *FSBox) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := fb.open("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := f.ReadDir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Sub returns a FSBox rooted at the given directory within this FSBox
+// (implementing fs.SubFS)
+func (fb *FSBox) Sub(dir string) (fs.FS, error) {
+	full, err := fb.resolve("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	return &FSBox{box: fb.box, dir: full}, nil
+}
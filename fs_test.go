@@ -0,0 +1,66 @@
+package rice
+
+import (
+	"errors"
+	"io/fs"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+func testBoxFileNames() []string {
+	var names []string
+	for name := range testBoxFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestFSConformance(t *testing.T) {
+	for method, box := range newTestBoxes(t) {
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			if err := fstest.TestFS(box.FS(), testBoxFileNames()...); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestFSSub(t *testing.T) {
+	for method, box := range newTestBoxes(t) {
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			sub, err := fs.Sub(box.FS(), "sub")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := sub.(*FSBox); !ok {
+				t.Fatalf("expected fs.Sub to return a *FSBox, got %T", sub)
+			}
+			if err := fstest.TestFS(sub, "a.txt", "b.txt", "deeper/c.txt", "deeper/nested/d.txt"); err != nil {
+				t.Fatal(err)
+			}
+			content, err := fs.ReadFile(sub, "deeper/c.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != testBoxFiles["sub/deeper/c.txt"] {
+				t.Fatalf("unexpected content %q", content)
+			}
+		})
+	}
+}
+
+func TestFSInvalidPath(t *testing.T) {
+	fsys := newEmbeddedTestBox().FS()
+	for _, name := range []string{"/file.txt", "../file.txt", "sub/../file.txt", "sub/", ""} {
+		_, err := fsys.Open(name)
+		if err == nil {
+			t.Errorf("Open(%q): expected an error", name)
+			continue
+		}
+		if !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Open(%q): expected fs.ErrInvalid, got %v", name, err)
+		}
+	}
+}
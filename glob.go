@@ -0,0 +1,168 @@
+package rice
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Glob returns the names of all files and directories in the box that match
+// the pattern, sorted by name. The syntax of the pattern is that of
+// path.Match, with slashes as separators and one addition: a "**" path
+// element matches zero or more directories, e.g. "migrations/**/*.sql"
+// matches "migrations/001.sql" and "migrations/2019/002.sql".
+//
+// Only the directories that can hold matches are listed: for embedded and
+// appended boxes through their index, for boxes on disk by reading them.
+// The only possible error is path.ErrBadPattern, or an *os.PathError
+// wrapping ErrPathEscape for a pattern that escapes from the box.
+func (b *Box) Glob(pattern string) ([]string, error) {
+	cleaned, err := cleanName("glob", pattern)
+	if err != nil {
+		return nil, err
+	}
+	var segs []string
+	if cleaned != "" {
+		segs = strings.Split(cleaned, "/")
+	}
+	for _, seg := range segs {
+		if _, err := path.Match(seg, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	found := make(map[string]bool)
+	b.glob("", segs, found)
+	// the root of the box is not a match, e.g. for "**"
+	delete(found, "")
+	matches := make([]string, 0, len(found))
+	for name := range found {
+		matches = append(matches, name)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// glob adds the names of the files and directories within dir that match
+// the pattern elements segs to found.
+func (b *Box) glob(dir string, segs []string, found map[string]bool) {
+	if len(segs) == 0 {
+		found[dir] = true
+		return
+	}
+	seg, rest := segs[0], segs[1:]
+
+	switch {
+	case seg == "**":
+		// zero directories
+		b.glob(dir, rest, found)
+		// one or more directories
+		for _, entry := range b.globEntries(dir) {
+			// "**" doesn't descend into symbolic links, so it can't loop
+			if entry.dir && !entry.link {
+				b.glob(path.Join(dir, entry.name), segs, found)
+			} else if len(rest) == 0 {
+				found[path.Join(dir, entry.name)] = true
+			}
+		}
+
+	case !hasGlobMeta(seg):
+		// no need to list the directory
+		name := path.Join(dir, seg)
+		info, err := b.stat(name)
+		if err != nil {
+			return
+		}
+		if len(rest) == 0 || info.IsDir() {
+			b.glob(name, rest, found)
+		}
+
+	default:
+		for _, entry := range b.globEntries(dir) {
+			if ok, _ := path.Match(seg, entry.name); !ok {
+				continue
+			}
+			if len(rest) == 0 || entry.dir {
+				b.glob(path.Join(dir, entry.name), rest, found)
+			}
+		}
+	}
+}
+
+// hasGlobMeta returns whether the pattern element has special characters
+func hasGlobMeta(seg string) bool {
+	return strings.ContainsAny(seg, `*?[\`)
+}
+
+// globEntry is an entry of a directory listing
+type globEntry struct {
+	name string
+	dir  bool // a directory, or a symbolic link to one
+	link bool // a symbolic link
+}
+
+// globEntries lists the directory with the given (clean) name, it returns
+// nothing when the directory can't be read.
+func (b *Box) globEntries(dir string) []globEntry {
+	var entries []globEntry
+
+	if b.IsEmbedded() {
+		ed := b.embed.Dirs[dir]
+		if ed == nil {
+			return nil
+		}
+		for _, child := range ed.ChildDirs {
+			entries = append(entries, globEntry{name: path.Base(child.Filename), dir: true})
+		}
+		for _, child := range ed.ChildFiles {
+			entries = append(entries, globEntry{name: path.Base(child.Filename)})
+		}
+		return entries
+	}
+
+	if b.IsAppended() {
+		af := b.appendd.Files[dir]
+		if af == nil || !af.dir {
+			return nil
+		}
+		for _, child := range af.children {
+			entries = append(entries, globEntry{name: child.info().Name(), dir: child.dir})
+		}
+		return entries
+	}
+
+	if b.isOverlay() {
+		infos, err := b.overlayReaddir(dir)
+		if err != nil {
+			return nil
+		}
+		for _, info := range infos {
+			entries = append(entries, globEntry{name: info.Name(), dir: info.IsDir()})
+		}
+		return entries
+	}
+
+	fullPath := filepath.Join(b.absolutePath, filepath.FromSlash(dir))
+	if b.denySymlinkEscape {
+		if err := b.checkSymlinks(fullPath); err != nil {
+			return nil
+		}
+	}
+	dirEntries, err := os.ReadDir(fullPath)
+	if err != nil {
+		return nil
+	}
+	for _, entry := range dirEntries {
+		ge := globEntry{name: entry.Name(), dir: entry.IsDir()}
+		if entry.Type()&os.ModeSymlink != 0 {
+			ge.link = true
+			if info, err := os.Stat(filepath.Join(fullPath, entry.Name())); err == nil {
+				ge.dir = info.IsDir()
+			}
+		}
+		entries = append(entries, ge)
+	}
+	return entries
+}
@@ -0,0 +1,89 @@
+package rice
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	allFiles := testBoxFileNames()
+	var all []string
+	for _, dir := range testBoxDirs(testBoxFiles) {
+		if dir != "" {
+			all = append(all, dir)
+		}
+	}
+	all = append(all, allFiles...)
+	sort.Strings(all)
+
+	cases := []struct {
+		pattern string
+		matches []string
+	}{
+		{"*.txt", []string{"empty.txt", "file.txt"}},
+		{"*", []string{"empty.txt", "file.txt", "sub"}},
+		{"sub/?.txt", []string{"sub/a.txt", "sub/b.txt"}},
+		{"sub/[ab].txt", []string{"sub/a.txt", "sub/b.txt"}},
+		{"sub/[^a].txt", []string{"sub/b.txt"}},
+		{"**/*.txt", allFiles},
+		{"sub/**", []string{"sub", "sub/a.txt", "sub/b.txt", "sub/deeper", "sub/deeper/c.txt", "sub/deeper/nested", "sub/deeper/nested/d.txt"}},
+		{"**/nested", []string{"sub/deeper/nested"}},
+		{"sub/**/d.txt", []string{"sub/deeper/nested/d.txt"}},
+		{"sub/**/**/c.txt", []string{"sub/deeper/c.txt"}},
+		{"*/*/*", []string{"sub/deeper/c.txt", "sub/deeper/nested"}},
+		{"**", all},
+		{"sub/deeper/c.txt", []string{"sub/deeper/c.txt"}},
+		{"/sub/./a.txt", []string{"sub/a.txt"}},
+		{"sub/a.txt/*", []string{}},
+		{"missing/**", []string{}},
+		{"", []string{}},
+	}
+	for method, box := range newTestBoxes(t) {
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			for _, c := range cases {
+				matches, err := box.Glob(c.pattern)
+				if err != nil {
+					t.Errorf("Glob(%q): %v", c.pattern, err)
+					continue
+				}
+				if !reflect.DeepEqual(matches, c.matches) {
+					t.Errorf("Glob(%q) = %q, expected %q", c.pattern, matches, c.matches)
+				}
+			}
+
+			if _, err := box.Glob("sub/[a.txt"); err != path.ErrBadPattern {
+				t.Errorf("expected path.ErrBadPattern, got %v", err)
+			}
+			if _, err := box.Glob("../*"); !errors.Is(err, ErrPathEscape) {
+				t.Errorf("expected ErrPathEscape, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGlobSymlinkLoop(t *testing.T) {
+	box := newFSTestBox(t)
+	if err := os.Symlink("..", filepath.Join(box.absolutePath, "sub", "loop")); err != nil {
+		t.Skip("symbolic links not supported:", err)
+	}
+	matches, err := box.Glob("**/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matches, []string{"sub/deeper/c.txt"}) {
+		t.Errorf("unexpected matches %q", matches)
+	}
+	// the link itself matches, and is followed for other patterns
+	matches, err = box.Glob("sub/*/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matches, []string{"sub/loop/file.txt"}) {
+		t.Errorf("unexpected matches %q", matches)
+	}
+}
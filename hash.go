@@ -0,0 +1,111 @@
+package rice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Hash returns the hex encoded SHA-256 of the content of the file with the
+// given name.
+//
+// The rice tool records the hash of every file it embeds or appends, except
+// for encrypted files. Files without a recorded hash, and files of boxes that
+// are loaded from disk, are hashed when the hash is first asked for. The hash
+// of a file on disk is computed again when its modification time or size
+// changes.
+// If there is an error, it will be of type *os.PathError.
+func (b *Box) Hash(name string) (string, error) {
+	name, err := cleanName("hash", name)
+	if err != nil {
+		return "", err
+	}
+	info, err := b.stat(name)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", &os.PathError{Op: "hash", Path: name, Err: syscall.EISDIR}
+	}
+
+	if b.isOverlay() {
+		// the layer records or caches the hash
+		layer, _, err := b.overlayStat(name)
+		if err != nil {
+			return "", err
+		}
+		return layer.Hash(name)
+	}
+	if b.IsEmbedded() {
+		if hash := b.embed.Files[name].Hash; hash != "" {
+			return hash, nil
+		}
+	}
+	if b.IsAppended() {
+		if hash := b.appendd.Files[name].hash; hash != "" {
+			return hash, nil
+		}
+	}
+
+	if hash, ok := b.hashes.get(name, info); ok {
+		return hash, nil
+	}
+	f, err := b.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	// the file may have changed since it was stat'ed
+	info, err = f.Stat()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", &os.PathError{Op: "hash", Path: name, Err: err}
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	b.hashes.set(name, info, hash)
+	return hash, nil
+}
+
+// hashCache holds the hashes computed by Box.Hash, keyed by file name
+type hashCache struct {
+	mu      sync.Mutex
+	entries map[string]hashCacheEntry
+}
+
+// hashCacheEntry is the hash of a file with the given modification time and size
+type hashCacheEntry struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+// get returns the cached hash of the file, when it didn't change since it was hashed
+func (c *hashCache) get(name string, info os.FileInfo) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[name]
+	if !ok || !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		return "", false
+	}
+	return entry.hash, true
+}
+
+func (c *hashCache) set(name string, info os.FileInfo, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]hashCacheEntry)
+	}
+	c.entries[name] = hashCacheEntry{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		hash:    hash,
+	}
+}
@@ -0,0 +1,106 @@
+package rice
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func sha256Hex(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestBoxHash(t *testing.T) {
+	for method, box := range newTestBoxes(t) {
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			for name, content := range testBoxFiles {
+				hash, err := box.Hash(name)
+				if err != nil {
+					t.Errorf("Hash(%q): %v", name, err)
+					continue
+				}
+				if hash != sha256Hex(content) {
+					t.Errorf("Hash(%q) = %s, expected %s", name, hash, sha256Hex(content))
+				}
+			}
+			if hash, err := box.Hash("/sub/../file.txt"); err != nil || hash != sha256Hex(testBoxFiles["file.txt"]) {
+				t.Errorf("Hash of an unclean name = %s, %v", hash, err)
+			}
+
+			_, err := box.Hash("missing.txt")
+			if !os.IsNotExist(err) {
+				t.Errorf("Hash of a missing file: expected a not exist error, got %v", err)
+			}
+			_, err = box.Hash("sub")
+			if !errors.Is(err, syscall.EISDIR) {
+				t.Errorf("Hash of a directory: expected EISDIR, got %v", err)
+			}
+			_, err = box.Hash("../file.txt")
+			if !errors.Is(err, ErrPathEscape) {
+				t.Errorf("Hash of a name outside of the box: expected ErrPathEscape, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBoxHashRecorded(t *testing.T) {
+	// recorded hashes are used as is, the content is not read
+	embeddedBox := newEmbeddedTestBox()
+	embeddedBox.embed.Files["file.txt"].Hash = "recorded"
+	appendedBox := newAppendedTestBox(t)
+	appendedBox.appendd.Files["file.txt"].hash = "recorded"
+
+	for _, box := range []*Box{embeddedBox, appendedBox} {
+		hash, err := box.Hash("file.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != "recorded" {
+			t.Errorf("expected the recorded hash, got %s", hash)
+		}
+	}
+}
+
+func TestBoxHashLive(t *testing.T) {
+	box := newFSTestBox(t)
+	fullPath := filepath.Join(box.absolutePath, "file.txt")
+
+	hash, err := box.Hash("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != sha256Hex(testBoxFiles["file.txt"]) {
+		t.Fatalf("unexpected hash %s", hash)
+	}
+
+	// the cached hash is used while the modification time and size don't change
+	if err := ioutil.WriteFile(fullPath, []byte("changed content of file.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box.hashes.set("file.txt", info, "cached")
+	if hash, _ := box.Hash("file.txt"); hash != "cached" {
+		t.Fatalf("expected the cached hash, got %s", hash)
+	}
+
+	if err := os.Chtimes(fullPath, time.Now(), info.ModTime().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	hash, err = box.Hash("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != sha256Hex("changed content of file.txt\n") {
+		t.Fatalf("expected the hash of the changed file, got %s", hash)
+	}
+}
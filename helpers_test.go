@@ -0,0 +1,201 @@
+package rice
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/archive"
+)
+
+// testBoxFiles is the content of the box used by the tests that run against
+// every backend. Directories are implied by the file paths.
+var testBoxFiles = map[string]string{
+	"file.txt":                "This is a file in the root of the box.\n",
+	"empty.txt":               "",
+	"sub/a.txt":               "file a in sub",
+	"sub/b.txt":               "file b in sub, which is a bit longer than file a",
+	"sub/deeper/c.txt":        "file c in sub/deeper",
+	"sub/deeper/nested/d.txt": strings.Repeat("d", 4096),
+}
+
+var testBoxModTime = time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testBoxDirs returns all directories implied by the file paths, including the root ("").
+func testBoxDirs(files map[string]string) []string {
+	dirs := map[string]bool{"": true}
+	for name := range files {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	var list []string
+	for dir := range dirs {
+		list = append(list, dir)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// newTestBoxes creates a box with the content of testBoxFiles for every
+// backend, keyed by the LocateMethod that would have found it.
+func newTestBoxes(t *testing.T) map[LocateMethod]*Box {
+	return newTestBoxesWithFiles(t, testBoxFiles)
+}
+
+// newTestBoxesWithFiles creates a box with the given files for every backend.
+func newTestBoxesWithFiles(t *testing.T, files map[string]string) map[LocateMethod]*Box {
+	return map[LocateMethod]*Box{
+		LocateEmbedded:         newEmbeddedTestBoxWithFiles(files),
+		LocateAppended:         newAppendedTestBoxWithFiles(t, files),
+		LocateFS:               newFSTestBoxWithFiles(t, files),
+		LocateWorkingDirectory: newWorkingDirectoryTestBoxWithFiles(t, files),
+	}
+}
+
+func newEmbeddedTestBox() *Box {
+	return newEmbeddedTestBoxWithFiles(testBoxFiles)
+}
+
+func newEmbeddedTestBoxWithFiles(files map[string]string) *Box {
+	eb := &embedded.EmbeddedBox{
+		Name:  "testbox",
+		Time:  testBoxModTime,
+		Files: make(map[string]*embedded.EmbeddedFile),
+		Dirs:  make(map[string]*embedded.EmbeddedDir),
+	}
+	for _, dir := range testBoxDirs(files) {
+		eb.Dirs[dir] = &embedded.EmbeddedDir{Filename: dir, DirModTime: testBoxModTime}
+	}
+	for name, content := range files {
+		eb.Files[name] = &embedded.EmbeddedFile{Filename: name, FileModTime: testBoxModTime, Content: content}
+	}
+	eb.Link()
+	return &Box{name: "testbox", embed: eb}
+}
+
+// newAppendedTestBox writes the test box to a zip file, the same way `rice append` does.
+func newAppendedTestBox(t *testing.T) *Box {
+	return newAppendedTestBoxWithFiles(t, testBoxFiles)
+}
+
+func newAppendedTestBoxWithFiles(t *testing.T, files map[string]string) *Box {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, dir := range testBoxDirs(files) {
+		header := &zip.FileHeader{Name: path.Join("testbox", dir), Comment: archive.DirAttr}
+		header.SetModTime(testBoxModTime)
+		if _, err := zw.CreateHeader(header); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		// mix stored and compressed files, they are read differently
+		method := zip.Deflate
+		if len(content)%2 == 0 {
+			method = zip.Store
+		}
+		header := &zip.FileHeader{
+			Name:    path.Join("testbox", name),
+			Method:  method,
+			Comment: archive.HashAttr + "=" + sha256Hex(content),
+		}
+		header.SetModTime(testBoxModTime)
+		header.SetMode(0644)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ra := bytes.NewReader(buf.Bytes())
+	rd, err := zip.NewReader(ra, ra.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes, errs := loadAppendedBoxes(ra, ra.Size(), rd)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	ab := boxes["testbox"]
+	if ab == nil {
+		t.Fatal("appended test box not found in zip")
+	}
+	return &Box{name: "testbox", appendd: ab}
+}
+
+func newFSTestBox(t *testing.T) *Box {
+	return newFSTestBoxWithFiles(t, testBoxFiles)
+}
+
+func newFSTestBoxWithFiles(t *testing.T, files map[string]string) *Box {
+	dir, err := ioutil.TempDir("", "rice-testbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range files {
+		fullPath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Box{name: "testbox", absolutePath: dir}
+}
+
+// newWorkingDirectoryTestBox locates a box on disk through the working directory.
+func newWorkingDirectoryTestBox(t *testing.T) *Box {
+	return newWorkingDirectoryTestBoxWithFiles(t, testBoxFiles)
+}
+
+func newWorkingDirectoryTestBoxWithFiles(t *testing.T, files map[string]string) *Box {
+	dir := newFSTestBoxWithFiles(t, files).absolutePath
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Dir(dir)); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	cfg := Config{LocateOrder: []LocateMethod{LocateWorkingDirectory}}
+	box, err := cfg.FindBox(filepath.Base(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+// locateMethodName is used to name subtests
+func locateMethodName(method LocateMethod) string {
+	switch method {
+	case LocateEmbedded:
+		return "embedded"
+	case LocateAppended:
+		return "appended"
+	case LocateFS:
+		return "fs"
+	case LocateWorkingDirectory:
+		return "workingdirectory"
+	}
+	return "unknown"
+}
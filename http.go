@@ -1,21 +1,313 @@
 package rice
 
 import (
+	"io"
+	"mime"
 	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
 )
 
 // HTTPBox implements http.FileSystem which allows the use of Box with a http.FileServer.
 //   e.g.: http.Handle("/", http.FileServer(rice.MustFindBox("http-files").HTTPBox()))
+//
+// HTTPBox is also a http.Handler, which serves the files like http.FileServer
+// does, and sends precompressed variants of files to clients that accept them.
+//   e.g.: http.Handle("/", rice.MustFindBox("http-files").HTTPBox())
 type HTTPBox struct {
 	*Box
+
+	// Fingerprinting makes ServeHTTP also serve files under their
+	// fingerprinted names (see Box.Fingerprint), with far-future caching.
+	Fingerprinting bool
 }
 
 // HTTPBox creates a new HTTPBox from an existing Box
 func (b *Box) HTTPBox() *HTTPBox {
-	return &HTTPBox{b}
+	return &HTTPBox{Box: b}
 }
 
+// Default cache headers
+const (
+	// DefaultFallbackCacheControl is the Cache-Control header of the fallback document of a SPAHandler
+	DefaultFallbackCacheControl = "no-cache"
+	// DefaultAssetCacheControl is the Cache-Control header of fingerprinted files and hashed assets
+	DefaultAssetCacheControl = "public, max-age=31536000, immutable"
+)
+
 // Open returns a File using the http.File interface
 func (hb *HTTPBox) Open(name string) (http.File, error) {
 	return hb.Box.Open(name)
 }
+
+// ServeHTTP serves the file from the box that matches the request path,
+// like http.FileServer does.
+//
+// When the client accepts it, a precompressed variant of the file is sent
+// instead of the file itself: a sibling file with the .br or .gz extension
+// (e.g. app.js.br for app.js), or the content of a file compressed by
+// `rice embed-go --compress`.
+//
+// Files are sent with a strong ETag, the hash of their content (see
+// Box.Hash), so requests with a matching If-None-Match are answered with
+// 304 Not Modified.
+func (hb *HTTPBox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, fingerprinted, ok := hb.requestedFile(r)
+	if !ok {
+		http.FileServer(hb).ServeHTTP(w, r)
+		return
+	}
+	if fingerprinted {
+		w.Header().Set("Cache-Control", DefaultAssetCacheControl)
+	}
+	hb.serveFile(w, r, name)
+}
+
+// requestedFile returns the name of the file to serve for the request, and
+// whether it was requested by its fingerprinted name. It returns false for
+// requests that are left to http.FileServer: directory listings, redirects
+// and missing files.
+func (hb *HTTPBox) requestedFile(r *http.Request) (name string, fingerprinted bool, ok bool) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return "", false, false
+	}
+	// http.FileServer redirects requests for index.html to the directory
+	if strings.HasSuffix(r.URL.Path, "/index.html") {
+		return "", false, false
+	}
+	name, err := cleanName("open", r.URL.Path)
+	if err != nil {
+		return "", false, false
+	}
+	info, err := hb.stat(name)
+	if err != nil {
+		if !hb.Fingerprinting || !os.IsNotExist(err) || strings.HasSuffix(r.URL.Path, "/") {
+			return "", false, false
+		}
+		name, ok := hb.unfingerprint(name)
+		return name, ok, ok
+	}
+	// http.FileServer redirects directories without and files with a trailing slash
+	if info.IsDir() != strings.HasSuffix(r.URL.Path, "/") {
+		return "", false, false
+	}
+	if !info.IsDir() {
+		return name, false, true
+	}
+
+	// like http.FileServer, serve index.html for a directory
+	index := path.Join(name, "index.html")
+	if info, err := hb.stat(index); err != nil || info.IsDir() {
+		return "", false, false
+	}
+	return index, false, true
+}
+
+// unfingerprint returns the name of the file with the given fingerprinted
+// name. It returns false when there is no such file, or when the fingerprint
+// is not the one of the current content of the file.
+func (hb *HTTPBox) unfingerprint(fingerprinted string) (string, bool) {
+	name, _, ok := splitFingerprint(fingerprinted)
+	if !ok {
+		return "", false
+	}
+	if current, err := hb.Fingerprint(name); err != nil || current != fingerprinted {
+		return "", false
+	}
+	return name, true
+}
+
+// serveFile serves the file with the given name, or its best precompressed
+// variant. Range requests are served from the chosen variant.
+func (hb *HTTPBox) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	info, err := hb.stat(name)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+
+	// the file itself is only opened when it is sent, or to detect its type
+	var f *File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	// the type of the content can't be detected from compressed data
+	ctype := mime.TypeByExtension(path.Ext(name))
+	if ctype == "" {
+		if f, err = hb.Box.Open(name); err != nil {
+			serveError(w, err)
+			return
+		}
+		var buf [512]byte
+		n, _ := io.ReadFull(f, buf[:])
+		ctype = http.DetectContentType(buf[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			serveError(w, err)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", ctype)
+
+	var content io.ReadSeeker
+	var variant *precompressedVariant
+	variants := hb.precompressedVariants(name)
+	if len(variants) > 0 {
+		// the response depends on Accept-Encoding, also when it is not compressed
+		w.Header().Add("Vary", "Accept-Encoding")
+		if variant = negotiateEncoding(r, variants); variant != nil {
+			vc, err := variant.open()
+			if err != nil {
+				serveError(w, err)
+				return
+			}
+			if closer, ok := vc.(io.Closer); ok {
+				defer closer.Close()
+			}
+			content = vc
+			w.Header().Set("Content-Encoding", variant.coding)
+		}
+	}
+	// http.ServeContent answers If-None-Match and If-Range with the ETag
+	if etag, err := hb.etag(name, variant); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+	if content == nil {
+		if f == nil {
+			if f, err = hb.Box.Open(name); err != nil {
+				serveError(w, err)
+				return
+			}
+		}
+		content = f
+	}
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
+
+// precompressedEncodings are the content codings of precompressed sibling
+// files, in order of preference, with the extension of the sibling.
+var precompressedEncodings = []struct {
+	coding string
+	ext    string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// precompressedVariant is a compressed version of a file
+type precompressedVariant struct {
+	coding string                       // content coding, e.g. "gzip"
+	name   string                       // name of the sibling file, "" for compressed embedded content
+	open   func() (io.ReadSeeker, error) // opens the compressed content
+}
+
+// precompressedVariants returns the precompressed variants of the file with
+// the given name, in order of preference.
+func (hb *HTTPBox) precompressedVariants(name string) []*precompressedVariant {
+	var variants []*precompressedVariant
+	for _, enc := range precompressedEncodings {
+		sibling := name + enc.ext
+		if info, err := hb.stat(sibling); err == nil && !info.IsDir() {
+			variants = append(variants, &precompressedVariant{
+				coding: enc.coding,
+				name:   sibling,
+				open: func() (io.ReadSeeker, error) {
+					return hb.Box.Open(sibling)
+				},
+			})
+			continue
+		}
+		// a file compressed by embed-go is its own gzip variant
+		if enc.coding == "gzip" && hb.IsEmbedded() {
+			if ef := hb.embed.Files[name]; ef != nil && ef.Compressed && !ef.Encrypted {
+				variants = append(variants, &precompressedVariant{
+					coding: "gzip",
+					open: func() (io.ReadSeeker, error) {
+						return strings.NewReader(ef.Content), nil
+					},
+				})
+			}
+		}
+	}
+	return variants
+}
+
+// etag returns the strong ETag of the file with the given name, as sent
+// with the given variant (nil for the file itself). Every variant has its
+// own ETag.
+func (hb *HTTPBox) etag(name string, variant *precompressedVariant) (string, error) {
+	switch {
+	case variant == nil:
+		hash, err := hb.Hash(name)
+		return `"` + hash + `"`, err
+	case variant.name != "":
+		hash, err := hb.Hash(variant.name)
+		return `"` + hash + `"`, err
+	default:
+		hash, err := hb.Hash(name)
+		return `"` + hash + "-" + variant.coding + `"`, err
+	}
+}
+
+// negotiateEncoding returns the variant the client accepts best, nil when it
+// accepts none of them. Variants the client accepts equally well are chosen
+// in the given order.
+func negotiateEncoding(r *http.Request, variants []*precompressedVariant) *precompressedVariant {
+	var best *precompressedVariant
+	bestQ := 0.0
+	for _, variant := range variants {
+		if q := acceptedEncodingQuality(r, variant.coding); q > bestQ {
+			best, bestQ = variant, q
+		}
+	}
+	return best
+}
+
+// acceptedEncodingQuality returns the quality value the Accept-Encoding header
+// of the request gives to the given content coding, e.g. "gzip". It is 0 when
+// the coding is not accepted.
+func acceptedEncodingQuality(r *http.Request, coding string) float64 {
+	quality := 0.0
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(header, ",") {
+			params := strings.Split(part, ";")
+			name := strings.ToLower(strings.TrimSpace(params[0]))
+			if name != coding && name != "*" {
+				continue
+			}
+			q := 1.0
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						q = v
+					}
+				}
+			}
+			if name == coding {
+				// an explicit coding overrides *
+				return q
+			}
+			quality = q
+		}
+	}
+	return quality
+}
+
+// serveError replies to the request with the HTTP error for the error from
+// opening a file, like http.FileServer does.
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		http.Error(w, "404 page not found", http.StatusNotFound)
+	case os.IsPermission(err):
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,293 @@
+package rice
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GeertJohan/go.rice/embedded"
+)
+
+// newCompressedTestBox creates an embedded box with files that are stored
+// gzip compressed, as `rice embed-go --compress` does.
+func newCompressedTestBox(t *testing.T, files map[string]string) *Box {
+	eb := &embedded.EmbeddedBox{
+		Name:  "compressed",
+		Time:  testBoxModTime,
+		Files: make(map[string]*embedded.EmbeddedFile),
+		Dirs:  map[string]*embedded.EmbeddedDir{"": {Filename: ""}},
+	}
+	for name, content := range files {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(content))
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		eb.Files[name] = &embedded.EmbeddedFile{
+			Filename:         name,
+			FileModTime:      testBoxModTime,
+			Content:          buf.String(),
+			Compressed:       true,
+			UncompressedSize: int64(len(content)),
+		}
+	}
+	eb.Link()
+	return &Box{name: "compressed", embed: eb}
+}
+
+func TestCompressedEmbeddedFile(t *testing.T) {
+	content := strings.Repeat("compressed content ", 20)
+	box := newCompressedTestBox(t, map[string]string{"file.txt": content})
+
+	s, err := box.String("file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != content {
+		t.Errorf("String() = %q, expected %q", s, content)
+	}
+	f := mustOpen(t, box, "file.txt")
+	defer f.Close()
+	read, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(read) != content {
+		t.Errorf("read %q, expected %q", read, content)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(content)) {
+		t.Errorf("Stat().Size() = %d, expected %d", info.Size(), len(content))
+	}
+}
+
+func TestHTTPBoxCompressed(t *testing.T) {
+	content := strings.Repeat("body { color: red; }\n", 20)
+	box := newCompressedTestBox(t, map[string]string{"style.css": content})
+	handler := box.HTTPBox()
+
+	cases := []struct {
+		acceptEncoding string
+		gzipped        bool
+	}{
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"*", true},
+		{"", false},
+		{"br", false},
+		{"gzip;q=0", false},
+		{"*, gzip;q=0", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/style.css", nil)
+		if c.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", c.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		resp := rec.Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%q: status %d", c.acceptEncoding, resp.StatusCode)
+			continue
+		}
+		if ctype := resp.Header.Get("Content-Type"); !strings.HasPrefix(ctype, "text/css") {
+			t.Errorf("%q: Content-Type %q", c.acceptEncoding, ctype)
+		}
+		if vary := resp.Header.Get("Vary"); vary != "Accept-Encoding" {
+			t.Errorf("%q: Vary %q", c.acceptEncoding, vary)
+		}
+		body := rec.Body.Bytes()
+		if c.gzipped {
+			if resp.Header.Get("Content-Encoding") != "gzip" {
+				t.Errorf("%q: expected gzip Content-Encoding, got %q", c.acceptEncoding, resp.Header.Get("Content-Encoding"))
+				continue
+			}
+			if string(body) != box.embed.Files["style.css"].Content {
+				t.Errorf("%q: expected the compressed content as stored", c.acceptEncoding)
+			}
+			continue
+		}
+		if resp.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%q: unexpected Content-Encoding %q", c.acceptEncoding, resp.Header.Get("Content-Encoding"))
+		}
+		if string(body) != content {
+			t.Errorf("%q: expected the uncompressed content, got %q", c.acceptEncoding, body)
+		}
+	}
+}
+
+func TestHTTPBoxPrecompressedSiblings(t *testing.T) {
+	const js = "console.log('app');\n"
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(js))
+	zw.Close()
+	files := map[string]string{
+		"app.js":       js,
+		"app.js.gz":    gz.String(),
+		"app.js.br":    "brotli compressed app.js",
+		"plain.txt":    "plain",
+		"sub/a.css":    "a {}",
+		"sub/a.css.gz": gz.String(),
+	}
+
+	cases := []struct {
+		path           string
+		acceptEncoding string
+		rangeHeader    string
+		status         int
+		encoding       string
+		body           string
+		vary           bool
+	}{
+		{"/app.js", "gzip, deflate, br", "", http.StatusOK, "br", files["app.js.br"], true},
+		{"/app.js", "gzip", "", http.StatusOK, "gzip", files["app.js.gz"], true},
+		{"/app.js", "br;q=0.5, gzip", "", http.StatusOK, "gzip", files["app.js.gz"], true},
+		{"/app.js", "*", "", http.StatusOK, "br", files["app.js.br"], true},
+		{"/app.js", "", "", http.StatusOK, "", js, true},
+		{"/app.js", "br;q=0, gzip;q=0", "", http.StatusOK, "", js, true},
+		{"/app.js", "br", "bytes=0-5", http.StatusPartialContent, "br", files["app.js.br"][:6], true},
+		{"/app.js", "", "bytes=8-", http.StatusPartialContent, "", js[8:], true},
+		{"/sub/a.css", "br, gzip", "", http.StatusOK, "gzip", files["sub/a.css.gz"], true},
+		{"/plain.txt", "br, gzip", "", http.StatusOK, "", "plain", false},
+		{"/app.js.gz", "gzip", "", http.StatusOK, "", files["app.js.gz"], false},
+		{"/missing.js", "gzip", "", http.StatusNotFound, "", "", false},
+	}
+	for method, box := range newTestBoxesWithFiles(t, files) {
+		handler := box.HTTPBox()
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			for _, c := range cases {
+				req := httptest.NewRequest("GET", c.path, nil)
+				if c.acceptEncoding != "" {
+					req.Header.Set("Accept-Encoding", c.acceptEncoding)
+				}
+				if c.rangeHeader != "" {
+					req.Header.Set("Range", c.rangeHeader)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				resp := rec.Result()
+
+				desc := c.path + " " + c.acceptEncoding + " " + c.rangeHeader
+				if resp.StatusCode != c.status {
+					t.Errorf("%s: status %d, expected %d", desc, resp.StatusCode, c.status)
+					continue
+				}
+				if c.status == http.StatusNotFound {
+					continue
+				}
+				if enc := resp.Header.Get("Content-Encoding"); enc != c.encoding {
+					t.Errorf("%s: Content-Encoding %q, expected %q", desc, enc, c.encoding)
+				}
+				if vary := resp.Header.Get("Vary") == "Accept-Encoding"; vary != c.vary {
+					t.Errorf("%s: Vary %q", desc, resp.Header.Get("Vary"))
+				}
+				if body := rec.Body.String(); body != c.body {
+					t.Errorf("%s: body %q, expected %q", desc, body, c.body)
+				}
+				ctype := resp.Header.Get("Content-Type")
+				switch {
+				case strings.HasSuffix(c.path, ".js") && !strings.Contains(ctype, "javascript"),
+					strings.HasSuffix(c.path, ".css") && !strings.HasPrefix(ctype, "text/css"),
+					strings.HasSuffix(c.path, ".txt") && !strings.HasPrefix(ctype, "text/plain"),
+					strings.HasSuffix(c.path, ".gz") && !strings.Contains(ctype, "gzip"):
+					t.Errorf("%s: Content-Type %q", desc, ctype)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPBoxETag(t *testing.T) {
+	files := map[string]string{
+		"app.js":    "console.log('app');\n",
+		"app.js.gz": "gzip compressed app.js",
+		"plain.txt": "plain",
+	}
+	cases := []struct {
+		path           string
+		acceptEncoding string
+		etag           string
+	}{
+		{"/plain.txt", "gzip", `"` + sha256Hex("plain") + `"`},
+		{"/app.js", "", `"` + sha256Hex(files["app.js"]) + `"`},
+		{"/app.js", "gzip", `"` + sha256Hex(files["app.js.gz"]) + `"`},
+	}
+
+	get := func(handler http.Handler, path, acceptEncoding, ifNoneMatch string) *http.Response {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	for method, box := range newTestBoxesWithFiles(t, files) {
+		handler := box.HTTPBox()
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			for _, c := range cases {
+				desc := c.path + " " + c.acceptEncoding
+				resp := get(handler, c.path, c.acceptEncoding, "")
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("%s: status %d", desc, resp.StatusCode)
+					continue
+				}
+				if etag := resp.Header.Get("ETag"); etag != c.etag {
+					t.Errorf("%s: ETag %s, expected %s", desc, etag, c.etag)
+				}
+
+				resp = get(handler, c.path, c.acceptEncoding, c.etag)
+				if resp.StatusCode != http.StatusNotModified {
+					t.Errorf("%s: matching If-None-Match: status %d, expected 304", desc, resp.StatusCode)
+				}
+				resp = get(handler, c.path, c.acceptEncoding, `"other", `+c.etag)
+				if resp.StatusCode != http.StatusNotModified {
+					t.Errorf("%s: If-None-Match list: status %d, expected 304", desc, resp.StatusCode)
+				}
+				resp = get(handler, c.path, c.acceptEncoding, `"other"`)
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("%s: other If-None-Match: status %d, expected 200", desc, resp.StatusCode)
+				}
+			}
+
+			// the ETag of one encoding doesn't match another
+			resp := get(handler, "/app.js", "gzip", `"`+sha256Hex(files["app.js"])+`"`)
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "gzip" {
+				t.Errorf("ETag of the identity encoding matched gzip: status %d", resp.StatusCode)
+			}
+		})
+	}
+
+	// compressed embedded content has its own ETag
+	handler := newCompressedTestBox(t, map[string]string{"app.js": files["app.js"]}).HTTPBox()
+	for _, c := range []struct {
+		acceptEncoding string
+		etag           string
+	}{
+		{"", `"` + sha256Hex(files["app.js"]) + `"`},
+		{"gzip", `"` + sha256Hex(files["app.js"]) + `-gzip"`},
+	} {
+		resp := get(handler, "/app.js", c.acceptEncoding, "")
+		if etag := resp.Header.Get("ETag"); etag != c.etag {
+			t.Errorf("compressed %q: ETag %s, expected %s", c.acceptEncoding, etag, c.etag)
+		}
+		resp = get(handler, "/app.js", c.acceptEncoding, c.etag)
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("compressed %q: status %d, expected 304", c.acceptEncoding, resp.StatusCode)
+		}
+	}
+}
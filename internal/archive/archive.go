@@ -0,0 +1,77 @@
+// Package archive defines the layout of the zip archives that the rice tool
+// appends to executables. It is shared between the go.rice package and the
+// rice tool.
+package archive
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Comment is set as the comment of the zip archive. It marks archives in
+// which every box is stored under the namespace (import path) of the package
+// that uses it. Archives without it store every box in a single directory,
+// named after the box with slashes replaced by dashes.
+const Comment = "go.rice"
+
+// IsNamespaced returns whether an archive with the given comment stores its
+// boxes under their namespace.
+func IsNamespaced(comment string) bool {
+	return comment == Comment || strings.HasPrefix(comment, Comment+"\n")
+}
+
+// The comment of a file in an archive holds its attributes, separated by
+// spaces. Attributes with a value are written as name=value.
+const (
+	DirAttr       = "dir"       // the file is a directory
+	EncryptedAttr = "encrypted" // the content of the file is encrypted
+	HashAttr      = "sha256"    // hex encoded SHA-256 of the content, not set for encrypted files
+)
+
+// HasAttr returns whether the file comment holds the given attribute.
+func HasAttr(comment, attr string) bool {
+	for _, a := range strings.Fields(comment) {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
+// AttrValue returns the value of the attribute with the given name in the
+// file comment, "" when the comment doesn't hold it.
+func AttrValue(comment, name string) string {
+	for _, a := range strings.Fields(comment) {
+		if strings.HasPrefix(a, name+"=") {
+			return a[len(name)+1:]
+		}
+	}
+	return ""
+}
+
+// BoxDir returns the directory within the archive that holds the files of
+// the given box.
+func BoxDir(namespace, name string) string {
+	return url.PathEscape(namespace) + "/" + url.PathEscape(name)
+}
+
+// SplitName splits the name of a file in a namespaced archive into the
+// namespace and name of the box it belongs to, and its path within the box.
+// The path is "" for the root directory of the box.
+func SplitName(name string) (namespace, box, file string, err error) {
+	parts := strings.SplitN(strings.TrimLeft(name, "/"), "/", 3)
+	if len(parts) < 2 {
+		return "", "", "", errors.New("file is not within a box: " + name)
+	}
+	if namespace, err = url.PathUnescape(parts[0]); err != nil {
+		return "", "", "", err
+	}
+	if box, err = url.PathUnescape(parts[1]); err != nil {
+		return "", "", "", err
+	}
+	if len(parts) == 3 {
+		file = parts[2]
+	}
+	return namespace, box, file, nil
+}
@@ -0,0 +1,128 @@
+package archive
+
+import (
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// An archive is signed by adding a line to its comment:
+//
+//	signature ed25519 <length> <base64 signature>
+//
+// The signature is an ed25519 signature over the SHA-512 digest of the
+// archive as it was before signing, which is <length> bytes long and has
+// Comment as its comment. Verifying reconstructs that archive from the signed
+// one, which only differs in the comment at the end of the archive.
+
+const signaturePrefix = "signature ed25519 "
+
+// directoryEndLen is the length of the end of central directory record,
+// without the comment. The last two bytes of the record hold the length of
+// the comment.
+const directoryEndLen = 22
+
+// directoryEndSignature starts the end of central directory record.
+const directoryEndSignature = 0x06054b50
+
+var (
+	// ErrNotSigned is returned when verifying an archive without signature.
+	ErrNotSigned = errors.New("archive is not signed")
+
+	// ErrInvalidSignature is returned when the signature of an archive
+	// doesn't match its data or the public key.
+	ErrInvalidSignature = errors.New("archive signature is invalid")
+)
+
+// Sign signs an archive that has Comment as its comment, and returns the
+// signed archive.
+func Sign(data []byte, key ed25519.PrivateKey) ([]byte, error) {
+	commentStart := len(data) - len(Comment)
+	directoryEnd := commentStart - directoryEndLen
+	if directoryEnd < 0 ||
+		binary.LittleEndian.Uint32(data[directoryEnd:]) != directoryEndSignature ||
+		string(data[commentStart:]) != Comment {
+		return nil, errors.New("archive doesn't end with the go.rice comment")
+	}
+
+	digest := sha512.Sum512(data)
+	signature := ed25519.Sign(key, digest[:])
+	comment := fmt.Sprintf("%s\n%s%d %s", Comment, signaturePrefix, len(data), base64.StdEncoding.EncodeToString(signature))
+
+	signed := make([]byte, 0, commentStart+len(comment)-len(Comment))
+	signed = append(signed, data[:commentStart-2]...)
+	signed = append(signed, 0, 0)
+	binary.LittleEndian.PutUint16(signed[commentStart-2:], uint16(len(comment)))
+	signed = append(signed, comment...)
+	return signed, nil
+}
+
+// Verify checks the signature of the archive at the end of r, which is size
+// bytes long. The comment is the comment of the archive, as read by
+// zip.Reader.
+func Verify(r io.ReaderAt, size int64, comment string, key ed25519.PublicKey) error {
+	if !IsNamespaced(comment) {
+		return ErrNotSigned
+	}
+	var line string
+	for _, l := range strings.Split(comment, "\n")[1:] {
+		if strings.HasPrefix(l, signaturePrefix) {
+			line = strings.TrimPrefix(l, signaturePrefix)
+			break
+		}
+	}
+	if line == "" {
+		return ErrNotSigned
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return ErrInvalidSignature
+	}
+	length, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	signature, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	// the signed archive must be at the very end of r
+	commentStart := size - int64(len(comment))
+	if commentStart < 0 {
+		return ErrInvalidSignature
+	}
+	tail := make([]byte, len(comment))
+	if _, err := r.ReadAt(tail, commentStart); err != nil {
+		return err
+	}
+	if string(tail) != comment {
+		return errors.New("archive is not at the end of the file")
+	}
+
+	// everything but the comment and its length is the same as before signing
+	unchanged := length - int64(len(Comment)) - 2
+	start := commentStart - 2 - unchanged
+	if unchanged < directoryEndLen-2 || start < 0 {
+		return ErrInvalidSignature
+	}
+	hash := sha512.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(r, start, unchanged)); err != nil {
+		return err
+	}
+	var commentLen [2]byte
+	binary.LittleEndian.PutUint16(commentLen[:], uint16(len(Comment)))
+	hash.Write(commentLen[:])
+	hash.Write([]byte(Comment))
+
+	if !ed25519.Verify(key, hash.Sum(nil), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
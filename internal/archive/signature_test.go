@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+)
+
+func newTestArchive(t *testing.T, prefix []byte) []byte {
+	buf := bytes.NewBuffer(append([]byte{}, prefix...))
+	zw := zip.NewWriter(buf)
+	zw.SetOffset(int64(len(prefix)))
+	if err := zw.SetComment(Comment); err != nil {
+		t.Fatal(err)
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:   BoxDir("example.com/pkg", "box") + "/file.txt",
+		Method: zip.Store,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("signed content"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := []byte("executable")
+	data := newTestArchive(t, prefix)
+	signed, err := Sign(data[len(prefix):], priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe := append(append([]byte{}, prefix...), signed...)
+
+	verify := func(exe []byte, key ed25519.PublicKey) error {
+		rd, err := zip.NewReader(bytes.NewReader(exe), int64(len(exe)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Verify(bytes.NewReader(exe), int64(len(exe)), rd.Comment, key)
+	}
+
+	if err := verify(exe, pub); err != nil {
+		t.Fatalf("verifying signed archive: %v", err)
+	}
+	if err := verify(exe, otherPub); err != ErrInvalidSignature {
+		t.Errorf("verifying with another key: expected ErrInvalidSignature, got %v", err)
+	}
+	if err := verify(data, pub); err != ErrNotSigned {
+		t.Errorf("verifying unsigned archive: expected ErrNotSigned, got %v", err)
+	}
+
+	// the file is stored, so its content can be found and changed
+	tampered := bytes.Replace(exe, []byte("signed content"), []byte("evil   content"), 1)
+	if bytes.Equal(tampered, exe) {
+		t.Fatal("content to tamper with not found")
+	}
+	if err := verify(tampered, pub); err != ErrInvalidSignature {
+		t.Errorf("verifying tampered archive: expected ErrInvalidSignature, got %v", err)
+	}
+
+	// the signature can't be replaced by signing some other archive
+	if _, err := Sign(exe, priv); err == nil {
+		t.Error("expected an error signing an archive that is already signed")
+	}
+}
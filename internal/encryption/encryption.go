@@ -0,0 +1,75 @@
+// Package encryption implements the encryption of the files in a box. It is
+// shared between the go.rice package and the rice tool.
+//
+// Files are encrypted with AES-GCM, using the path of the file within the box
+// as additional data, so encrypted files can't be swapped. The nonce is
+// derived from the key, the path and the content of the file, which keeps the
+// output of the rice tool reproducible.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+)
+
+// Overhead is the number of bytes an encrypted file is longer than the
+// original file.
+const Overhead = nonceSize + tagSize
+
+const (
+	nonceSize = 12
+	tagSize   = 16
+)
+
+// ErrDecrypt is returned when a file can't be decrypted, because the key is
+// wrong or the file was modified.
+var ErrDecrypt = errors.New("message authentication failed")
+
+// newGCM creates the AES-GCM cipher for a 16, 24 or 32 byte key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts the content of the file with the given path within its
+// box. The result holds the nonce followed by the encrypted content.
+func Encrypt(key []byte, name string, content []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// a separate key for nonces, so the encryption key isn't used for two purposes
+	nonceKey := hmac.New(sha256.New, key)
+	nonceKey.Write([]byte("go.rice nonce"))
+	mac := hmac.New(sha256.New, nonceKey.Sum(nil))
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write(content)
+	nonce := mac.Sum(nil)[:nonceSize]
+
+	return gcm.Seal(nonce, nonce, content, []byte(name)), nil
+}
+
+// Decrypt decrypts content that was encrypted by Encrypt for the file with the
+// given path.
+func Decrypt(key []byte, name string, encrypted []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < Overhead {
+		return nil, ErrDecrypt
+	}
+	content, err := gcm.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], []byte(name))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return content, nil
+}
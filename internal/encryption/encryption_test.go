@@ -0,0 +1,53 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+	content := []byte("licensed content")
+
+	encrypted, err := Encrypt(key, "sub/file.txt", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encrypted) != len(content)+Overhead {
+		t.Errorf("encrypted length is %d, expected %d", len(encrypted), len(content)+Overhead)
+	}
+	if bytes.Contains(encrypted, content) {
+		t.Error("encrypted data contains the content")
+	}
+
+	// encrypting is deterministic
+	again, err := Encrypt(key, "sub/file.txt", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encrypted, again) {
+		t.Error("encrypting the same file twice gives different results")
+	}
+
+	decrypted, err := Decrypt(key, "sub/file.txt", encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, content) {
+		t.Errorf("decrypted %q, expected %q", decrypted, content)
+	}
+
+	if _, err := Decrypt(otherKey, "sub/file.txt", encrypted); err != ErrDecrypt {
+		t.Errorf("decrypting with another key: expected ErrDecrypt, got %v", err)
+	}
+	if _, err := Decrypt(key, "other.txt", encrypted); err != ErrDecrypt {
+		t.Errorf("decrypting as another file: expected ErrDecrypt, got %v", err)
+	}
+	if _, err := Decrypt(key, "sub/file.txt", encrypted[:Overhead-1]); err != ErrDecrypt {
+		t.Errorf("decrypting truncated data: expected ErrDecrypt, got %v", err)
+	}
+	if _, err := Encrypt([]byte("short"), "file.txt", content); err == nil {
+		t.Error("expected an error for an invalid key size")
+	}
+}
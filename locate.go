@@ -0,0 +1,246 @@
+package rice
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/GeertJohan/go.rice/embedded"
+)
+
+// ErrBoxNotFound is reported by a Locator that doesn't have the box. Errors
+// returned by FindBox always match it with errors.Is.
+var ErrBoxNotFound = errors.New("box not found")
+
+// Locator locates boxes for FindBox. Register a Locator with
+// RegisterLocator to use it in Config.LocateOrder.
+type Locator interface {
+	// Locate returns the requested box, or an error that tells why the box
+	// can't be located. The box is usually created with req.DirBox or
+	// req.EmbeddedBox, so it has the settings of req.Config.
+	Locate(req *LocateRequest) (*Box, error)
+}
+
+// LocatorFunc is a function that implements Locator.
+type LocatorFunc func(req *LocateRequest) (*Box, error)
+
+// Locate calls f(req)
+func (f LocatorFunc) Locate(req *LocateRequest) (*Box, error) {
+	return f(req)
+}
+
+// LocateRequest describes the box that FindBox searches for.
+type LocateRequest struct {
+	Name    string  // name of the box, as given to FindBox
+	Package string  // import path of the package that called FindBox, "main" for commands
+	Config  *Config // the config that FindBox was called with
+
+	// path of the box next to the source of the caller, see LocateFS
+	callerPath    string
+	callerPathErr error
+}
+
+// DirBox returns a box for the directory on disk, with the settings of the
+// config. If there is an error, it will be of type *os.PathError.
+func (req *LocateRequest) DirBox(dir string) (*Box, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "locate", Path: dir, Err: syscall.ENOTDIR}
+	}
+	b := req.newBox()
+	b.absolutePath = dir
+	return b, nil
+}
+
+// EmbeddedBox returns a box for the embedded box, with the settings of the
+// config. The embedded box doesn't need to be registered, e.g. to provide a
+// fixture for tests.
+func (req *LocateRequest) EmbeddedBox(eb *embedded.EmbeddedBox) *Box {
+	b := req.newBox()
+	b.embed = eb
+	return b
+}
+
+// newBox creates a box with the settings of the config
+func (req *LocateRequest) newBox() *Box {
+	return &Box{
+		name:              req.Name,
+		denySymlinkEscape: req.Config.DenySymlinkEscape,
+		decryptionKeyFunc: req.Config.decryptionKeyFunc(),
+		watchInterval:     req.Config.WatchInterval,
+	}
+}
+
+// LocateError is returned by FindBox when no Locator of the LocateOrder
+// located the box.
+type LocateError struct {
+	Name     string          // name of the box
+	Failures []LocateFailure // why each Locator failed, in the LocateOrder
+}
+
+// LocateFailure tells why a Locator didn't locate a box.
+type LocateFailure struct {
+	Method LocateMethod
+	Err    error
+}
+
+func (e *LocateError) Error() string {
+	if len(e.Failures) == 0 {
+		return fmt.Sprintf("could not locate box %q", e.Name)
+	}
+	reasons := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		reasons[i] = failure.Method.String() + ": " + failure.Err.Error()
+	}
+	return fmt.Sprintf("could not locate box %q: %s", e.Name, strings.Join(reasons, "; "))
+}
+
+// Is reports whether the target is ErrBoxNotFound, or matches the error of
+// one of the failures, e.g. ErrInvalidSignature.
+func (e *LocateError) Is(target error) bool {
+	if target == ErrBoxNotFound {
+		return true
+	}
+	for _, failure := range e.Failures {
+		if errors.Is(failure.Err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	locatorsMu sync.RWMutex
+	locators   = []registeredLocator{
+		LocateFS:               {"fs", fsLocator{}},
+		LocateAppended:         {"appended", appendedLocator{}},
+		LocateEmbedded:         {"embedded", embeddedLocator{}},
+		LocateWorkingDirectory: {"workingdirectory", workingDirectoryLocator{}},
+		LocateBundle:           {"bundle", bundleLocator{}},
+		LocateEnv:              {"env", envLocator{}},
+	}
+)
+
+// registeredLocator is a Locator with the name it was registered with
+type registeredLocator struct {
+	name    string
+	locator Locator
+}
+
+// RegisterLocator makes a Locator available under the returned LocateMethod,
+// for use in Config.LocateOrder. The name is used in errors and logs, it
+// panics when the name is already registered. Register locators from init
+// functions or before the first call to FindBox:
+//
+//	var LocateNextToExecutable = rice.RegisterLocator("executable", rice.LocatorFunc(
+//		func(req *rice.LocateRequest) (*rice.Box, error) {
+//			exe, err := os.Executable()
+//			if err != nil {
+//				return nil, err
+//			}
+//			return req.DirBox(filepath.Join(filepath.Dir(exe), req.Name))
+//		}))
+func RegisterLocator(name string, locator Locator) LocateMethod {
+	if locator == nil {
+		panic("rice: RegisterLocator locator is nil")
+	}
+	locatorsMu.Lock()
+	defer locatorsMu.Unlock()
+	for _, registered := range locators {
+		if registered.name == name {
+			panic("rice: RegisterLocator called twice for locator " + name)
+		}
+	}
+	locators = append(locators, registeredLocator{name, locator})
+	return LocateMethod(len(locators) - 1)
+}
+
+// lookupLocator returns the Locator registered for the method, nil when
+// there is none
+func lookupLocator(method LocateMethod) Locator {
+	locatorsMu.RLock()
+	defer locatorsMu.RUnlock()
+	if method < 0 || int(method) >= len(locators) {
+		return nil
+	}
+	return locators[method].locator
+}
+
+// lookupLocateMethod returns the method registered with the given name
+func lookupLocateMethod(name string) (LocateMethod, bool) {
+	locatorsMu.RLock()
+	defer locatorsMu.RUnlock()
+	for method, registered := range locators {
+		if registered.name == name {
+			return LocateMethod(method), true
+		}
+	}
+	return 0, false
+}
+
+// String returns the name the method was registered with, e.g. "embedded"
+func (method LocateMethod) String() string {
+	locatorsMu.RLock()
+	defer locatorsMu.RUnlock()
+	if method < 0 || int(method) >= len(locators) {
+		return fmt.Sprintf("LocateMethod(%d)", int(method))
+	}
+	return locators[method].name
+}
+
+// embeddedLocator implements LocateEmbedded
+type embeddedLocator struct{}
+
+func (embeddedLocator) Locate(req *LocateRequest) (*Box, error) {
+	embed := findEmbeddedBox(req.Package, req.Name)
+	if embed == nil {
+		return nil, fmt.Errorf("%w for package %q", ErrBoxNotFound, req.Package)
+	}
+	return req.EmbeddedBox(embed), nil
+}
+
+// appendedLocator implements LocateAppended
+type appendedLocator struct{}
+
+func (appendedLocator) Locate(req *LocateRequest) (*Box, error) {
+	appendd := findAppendedBox(req.Package, req.Name)
+	if appendd == nil {
+		return nil, fmt.Errorf("%w for package %q", ErrBoxNotFound, req.Package)
+	}
+	if req.Config.AppendedPublicKey != nil {
+		if err := appendd.archive.verify(req.Config.AppendedPublicKey); err != nil {
+			return nil, fmt.Errorf("refusing appended box: %w", err)
+		}
+	}
+	b := req.newBox()
+	b.appendd = appendd
+	return b, nil
+}
+
+// fsLocator implements LocateFS
+type fsLocator struct{}
+
+func (fsLocator) Locate(req *LocateRequest) (*Box, error) {
+	if req.callerPathErr != nil {
+		return nil, req.callerPathErr
+	}
+	return req.DirBox(req.callerPath)
+}
+
+// workingDirectoryLocator implements LocateWorkingDirectory
+type workingDirectoryLocator struct{}
+
+func (workingDirectoryLocator) Locate(req *LocateRequest) (*Box, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return req.DirBox(filepath.Join(wd, req.Name))
+}
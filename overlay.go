@@ -0,0 +1,223 @@
+package rice
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Whiteouts hide files of lower layers of an overlay box, like they do in
+// overlay filesystems and container images.
+const (
+	// WhiteoutPrefix marks a whiteout file: ".wh.name" in a layer hides
+	// "name" in the same directory of the layers below it.
+	WhiteoutPrefix = ".wh."
+
+	// WhiteoutOpaque marks an opaque directory: the content of a directory
+	// with this file is not merged with the same directory of the layers
+	// below it.
+	WhiteoutOpaque = WhiteoutPrefix + WhiteoutPrefix + ".opq"
+)
+
+// Overlay creates a box that layers the given boxes, e.g. a directory with
+// overrides on disk over an embedded box with defaults. Every file is opened
+// from the first box (layer) that has it, directory listings hold the files
+// of all layers. A layer hides a file of the layers below it with a whiteout
+// (see WhiteoutPrefix), a directory with WhiteoutOpaque, or a file that has
+// the name of a directory in the layers below it. Whiteouts themselves are
+// never visible.
+//
+// The overlay box is neither embedded nor appended. It reports the changes
+// to all layers through Watch. An overlay of no boxes has no files.
+func Overlay(boxes ...*Box) *Box {
+	// the layers are never nil, that would make it a box on disk
+	b := &Box{overlay: append([]*Box{}, boxes...)}
+	for _, layer := range boxes {
+		if b.name == "" {
+			b.name = layer.name
+		}
+		// watch as often as the layer that is watched most often
+		if layer.watchInterval > 0 && (b.watchInterval == 0 || layer.watchInterval < b.watchInterval) {
+			b.watchInterval = layer.watchInterval
+		}
+	}
+	return b
+}
+
+// isOverlay indicates whether the box layers other boxes, see Overlay
+func (b *Box) isOverlay() bool {
+	return b.overlay != nil
+}
+
+// isLive indicates whether the content of the box can change, because (one
+// of its layers) is loaded from disk
+func (b *Box) isLive() bool {
+	if b.isOverlay() {
+		for _, layer := range b.overlay {
+			if layer.isLive() {
+				return true
+			}
+		}
+		return false
+	}
+	return !b.IsEmbedded() && !b.IsAppended()
+}
+
+// overlayTime returns the latest Time of the layers
+func (b *Box) overlayTime() time.Time {
+	var latest time.Time
+	for _, layer := range b.overlay {
+		if t := layer.Time(); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// overlayStat returns the layer that provides the file or directory with the
+// given (clean) name, and its os.FileInfo.
+func (b *Box) overlayStat(name string) (*Box, os.FileInfo, error) {
+	if isWhiteout(name) {
+		return nil, nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	for _, layer := range b.overlay {
+		info, err := layer.stat(name)
+		if err == nil {
+			return layer, info, nil
+		}
+		if !isNotExist(err) {
+			return nil, nil, err
+		}
+		if overlayHides(layer, name) {
+			break
+		}
+	}
+	return nil, nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+// openOverlay opens the file with the given (clean) name from the layer that
+// has it, or a directory with the merged listing of all layers.
+func (b *Box) openOverlay(name string) (*File, error) {
+	layer, info, err := b.overlayStat(name)
+	if err != nil {
+		if pathErr, ok := err.(*os.PathError); ok {
+			pathErr.Op = "open"
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return layer.Open(name)
+	}
+	entries, err := b.overlayReaddir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &File{virtualD: newVirtualDir(name, info, entries)}, nil
+}
+
+// overlayReaddir returns the merged listing of the directory with the given
+// (clean) name, sorted by name.
+func (b *Box) overlayReaddir(name string) ([]os.FileInfo, error) {
+	merged := make(map[string]os.FileInfo)
+	hidden := make(map[string]bool)
+	for _, layer := range b.overlay {
+		info, err := layer.stat(name)
+		if err == nil && !info.IsDir() {
+			// a file hides the directories below it
+			break
+		}
+		if err == nil {
+			entries, err := layer.readdir(name)
+			if err != nil {
+				return nil, err
+			}
+			// whiteouts of a layer only hide the files of the layers below it
+			var whiteouts []string
+			for _, entry := range entries {
+				entryName := entry.Name()
+				if strings.HasPrefix(entryName, WhiteoutPrefix) {
+					whiteouts = append(whiteouts, strings.TrimPrefix(entryName, WhiteoutPrefix))
+					continue
+				}
+				if _, ok := merged[entryName]; !ok && !hidden[entryName] {
+					merged[entryName] = entry
+				}
+			}
+			for _, whiteout := range whiteouts {
+				hidden[whiteout] = true
+			}
+		} else if !isNotExist(err) {
+			return nil, err
+		}
+		if _, err := layer.stat(path.Join(name, WhiteoutOpaque)); err == nil || overlayHides(layer, name) {
+			break
+		}
+	}
+
+	entries := make([]os.FileInfo, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Sort(SortByName(entries))
+	return entries, nil
+}
+
+// readdir returns the listing of the directory with the given (clean) name
+func (b *Box) readdir(name string) ([]os.FileInfo, error) {
+	f, err := b.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(0)
+}
+
+// overlayHides returns whether the layer hides the file or directory with
+// the given (clean) name from the layers below it, with a whiteout of it or
+// of one of its parent directories, an opaque parent directory, or a parent
+// directory that is a file in the layer.
+func overlayHides(layer *Box, name string) bool {
+	exists := func(name string) bool {
+		_, err := layer.stat(name)
+		return err == nil
+	}
+	for p := name; p != ""; {
+		dir := path.Dir(p)
+		if dir == "." {
+			dir = ""
+		}
+		if exists(path.Join(dir, WhiteoutPrefix+path.Base(p))) {
+			return true
+		}
+		if exists(path.Join(dir, WhiteoutOpaque)) {
+			return true
+		}
+		if dir != "" {
+			if info, err := layer.stat(dir); err == nil && !info.IsDir() {
+				return true
+			}
+		}
+		p = dir
+	}
+	return false
+}
+
+// isWhiteout returns whether the name refers to (a file within) a whiteout
+func isWhiteout(name string) bool {
+	for _, element := range strings.Split(name, "/") {
+		if strings.HasPrefix(element, WhiteoutPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotExist returns whether the error reports that a file doesn't exist in
+// a layer, also when a parent directory is a file
+func isNotExist(err error) bool {
+	return os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR)
+}
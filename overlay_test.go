@@ -0,0 +1,236 @@
+package rice
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newOverlayTestBox layers a box on disk with overrides and whiteouts over
+// the embedded test box
+func newOverlayTestBox(t *testing.T) (*Box, *Box) {
+	upper := newFSTestBoxWithFiles(t, map[string]string{
+		"file.txt":                     "overridden",
+		"new.txt":                      "only in the upper layer",
+		".wh.empty.txt":                "",
+		"sub/deeper/c.txt":             "c is back in an opaque directory",
+		"sub/deeper/" + WhiteoutOpaque: "",
+		"other/.wh.missing.txt":        "",
+		"other/x.txt":                  "x",
+		"sub/b.txt/.keep":              "",
+	})
+	return Overlay(upper, newEmbeddedTestBox()), upper
+}
+
+func TestOverlayOpen(t *testing.T) {
+	box, _ := newOverlayTestBox(t)
+
+	cases := map[string]string{
+		"file.txt":         "overridden",
+		"new.txt":          "only in the upper layer",
+		"sub/a.txt":        testBoxFiles["sub/a.txt"],
+		"sub/deeper/c.txt": "c is back in an opaque directory",
+		"other/x.txt":      "x",
+	}
+	for name, expected := range cases {
+		content, err := box.String(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if content != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, content)
+		}
+	}
+
+	for _, name := range []string{
+		"empty.txt",               // whiteout
+		"sub/deeper/nested/d.txt", // in an opaque directory
+		".wh.empty.txt",           // whiteouts are never visible
+		"sub/deeper/" + WhiteoutOpaque,
+		"missing.txt",
+	} {
+		if _, err := box.Open(name); !os.IsNotExist(err) {
+			t.Errorf("%s: expected not exist, got %v", name, err)
+		}
+	}
+
+	// a directory of the upper layer hides the file below it
+	info, err := box.stat("sub/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Error("expected sub/b.txt to be a directory")
+	}
+}
+
+func TestOverlayEmpty(t *testing.T) {
+	box := Overlay()
+	// overlay.go is in the working directory of the test
+	for _, name := range []string{"overlay.go", "missing.txt"} {
+		if _, err := box.Open(name); !os.IsNotExist(err) {
+			t.Errorf("%s: expected not exist, got %v", name, err)
+		}
+	}
+	if box.IsEmbedded() || box.IsAppended() {
+		t.Error("expected an empty overlay to be neither embedded nor appended")
+	}
+	// not even the root directory exists
+	if _, err := box.Open(""); !os.IsNotExist(err) {
+		t.Errorf("expected the root to not exist, got %v", err)
+	}
+}
+
+func TestOverlayWhiteoutDirectory(t *testing.T) {
+	upper := newFSTestBoxWithFiles(t, map[string]string{
+		".wh.sub": "",
+	})
+	box := Overlay(upper, newEmbeddedTestBox())
+
+	if _, err := box.Open("sub/deeper/c.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist, got %v", err)
+	}
+	matches, err := box.Glob("*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matches, []string{"empty.txt", "file.txt"}) {
+		t.Errorf("unexpected matches %q", matches)
+	}
+}
+
+func TestOverlayFileHidesDirectory(t *testing.T) {
+	upper := newFSTestBoxWithFiles(t, map[string]string{
+		"sub": "a file",
+	})
+	box := Overlay(upper, newEmbeddedTestBox())
+
+	content, err := box.String("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "a file" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if _, err := box.Open("sub/a.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist, got %v", err)
+	}
+}
+
+func TestOverlayWalk(t *testing.T) {
+	box, _ := newOverlayTestBox(t)
+
+	var names []string
+	err := box.Walk("", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"",
+		"file.txt",
+		"new.txt",
+		"other",
+		"other/x.txt",
+		"sub",
+		"sub/a.txt",
+		"sub/b.txt",
+		"sub/b.txt/.keep",
+		"sub/deeper",
+		"sub/deeper/c.txt",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Walk visited %q, expected %q", names, expected)
+	}
+
+	matches, err := box.Glob("sub/**/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(matches, []string{"sub/a.txt", "sub/b.txt", "sub/deeper/c.txt"}) {
+		t.Errorf("unexpected matches %q", matches)
+	}
+}
+
+func TestOverlayHash(t *testing.T) {
+	box, _ := newOverlayTestBox(t)
+	for name, content := range map[string]string{
+		"file.txt":  "overridden",
+		"sub/a.txt": testBoxFiles["sub/a.txt"],
+	} {
+		hash, err := box.Hash(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != sha256Hex(content) {
+			t.Errorf("%s: unexpected hash %s", name, hash)
+		}
+	}
+}
+
+func TestOverlayHTTPBox(t *testing.T) {
+	box, _ := newOverlayTestBox(t)
+	server := httptest.NewServer(http.FileServer(box.HTTPBox()))
+	defer server.Close()
+
+	for name, expected := range map[string]string{
+		"/file.txt":  "overridden",
+		"/sub/a.txt": testBoxFiles["sub/a.txt"],
+	} {
+		resp, err := http.Get(server.URL + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != expected {
+			t.Errorf("%s: got %d %q", name, resp.StatusCode, body)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/empty.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a whiteout, got %d", resp.StatusCode)
+	}
+}
+
+func TestOverlayWatch(t *testing.T) {
+	box, upper := newOverlayTestBox(t)
+	box.watchInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []Event, 1)
+	go box.Watch(ctx, func(events []Event) { changes <- events })
+	// let Watch scan the box before changing it
+	time.Sleep(50 * time.Millisecond)
+
+	// removing the whiteout brings back the file of the lower layer
+	if err := os.Remove(filepath.Join(upper.absolutePath, ".wh.empty.txt")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case events := <-changes:
+		if !reflect.DeepEqual(events, []Event{{"empty.txt", EventCreate}}) {
+			t.Errorf("unexpected events %v", events)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+}
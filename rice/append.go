@@ -2,18 +2,29 @@ package main
 
 import (
 	"archive/zip"
+	"crypto/ed25519"
 	"fmt"
 	"go/build"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/GeertJohan/go.rice/internal/archive"
+	"github.com/GeertJohan/go.rice/internal/encryption"
 	zipexe "github.com/daaku/go.zipexe"
 )
 
 func operationAppend(pkgs []*build.Package) {
+	// read the keys before doing any work
+	signKey, encryptionKey := readArchiveKeys(flags.Append.SignKey, flags.Append.EncryptKey)
+	if flags.Append.Bundle != "" && encryptionKey != nil {
+		fmt.Printf("Cannot encrypt the files of a bundle, use --encrypt-key with rice pack instead.\n")
+		os.Exit(1)
+	}
+
 	// create tmp zipfile
 	tmpZipfileName := filepath.Join(os.TempDir(), fmt.Sprintf("ricebox-%d-%s.zip", time.Now().Unix(), randomString(10)))
 	verbosef("Will create tmp zipfile: %s\n", tmpZipfileName)
@@ -61,6 +72,96 @@ func operationAppend(pkgs []*build.Package) {
 	// write the zip offset into the zip data
 	zipWriter.SetOffset(binfileInfo.Size())
 
+	// mark the archive as storing boxes by namespace
+	if err := zipWriter.SetComment(archive.Comment); err != nil {
+		fmt.Printf("Error setting zip comment: %s\n", err)
+		os.Exit(1)
+	}
+
+	if flags.Append.Bundle != "" {
+		copyBundle(zipWriter, flags.Append.Bundle, modTimeFromFlags())
+	} else {
+		writeBoxes(zipWriter, pkgs, encryptionKey, modTimeFromFlags(), fileRulesFromFlags())
+	}
+
+	err = zipWriter.Close()
+	if err != nil {
+		fmt.Printf("Error closing tmp zipfile: %s\n", err)
+		os.Exit(1)
+	}
+
+	err = tmpZipfile.Sync()
+	if err != nil {
+		fmt.Printf("Error syncing tmp zipfile: %s\n", err)
+		os.Exit(1)
+	}
+	_, err = tmpZipfile.Seek(0, 0)
+	if err != nil {
+		fmt.Printf("Error seeking tmp zipfile: %s\n", err)
+		os.Exit(1)
+	}
+	_, err = binfile.Seek(0, 2)
+	if err != nil {
+		fmt.Printf("Error seeking bin file: %s\n", err)
+		os.Exit(1)
+	}
+
+	if signKey == nil {
+		_, err = io.Copy(binfile, tmpZipfile)
+		if err != nil {
+			fmt.Printf("Error appending zipfile to executable: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// the signature is added to the comment at the end of the archive
+	zipData, err := ioutil.ReadAll(tmpZipfile)
+	if err != nil {
+		fmt.Printf("Error reading tmp zipfile: %s\n", err)
+		os.Exit(1)
+	}
+	zipData, err = archive.Sign(zipData, signKey)
+	if err != nil {
+		fmt.Printf("Error signing zipfile: %s\n", err)
+		os.Exit(1)
+	}
+	_, err = binfile.Write(zipData)
+	if err != nil {
+		fmt.Printf("Error appending zipfile to executable: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// readArchiveKeys reads the keys to sign and encrypt an archive with, from
+// the files given with --sign-key and --encrypt-key
+func readArchiveKeys(signKeyFile, encryptKeyFile string) (ed25519.PrivateKey, []byte) {
+	var signKey ed25519.PrivateKey
+	if signKeyFile != "" {
+		var err error
+		signKey, err = readPrivateKey(signKeyFile)
+		if err != nil {
+			fmt.Printf("Error reading signing key: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	var encryptionKey []byte
+	if encryptKeyFile != "" {
+		var err error
+		encryptionKey, err = readEncryptionKey(encryptKeyFile)
+		if err != nil {
+			fmt.Printf("Error reading encryption key: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	return signKey, encryptionKey
+}
+
+// writeBoxes writes the files of the boxes of the packages to the zip archive,
+// every box in the directory named after its namespace and name. The modTime
+// is recorded instead of the modification times when it's set, the files
+// that the rules skip are left out.
+func writeBoxes(zipWriter *zip.Writer, pkgs []*build.Package, encryptionKey []byte, modTime time.Time, rules fileRules) {
 	for _, pkg := range pkgs {
 		// find boxes for this command
 		boxMap := findBoxes(pkg)
@@ -73,25 +174,38 @@ func operationAppend(pkgs []*build.Package) {
 
 		verbosef("\n")
 
-		for boxname := range boxMap {
-			appendedBoxName := strings.Replace(boxname, `/`, `-`, -1)
+		for _, boxname := range sortedNames(boxMap) {
+			boxDir := archive.BoxDir(boxNamespace(pkg), boxname)
 
 			// walk box path's and insert files
 			boxPath := filepath.Clean(filepath.Join(pkg.Dir, boxname))
+			filter, err := rules.filterForBox(boxPath)
+			if err != nil {
+				fmt.Printf("Error reading %s: %s\n", ignoreFilename, err)
+				os.Exit(1)
+			}
 			filepath.Walk(boxPath, func(path string, info os.FileInfo, err error) error {
 				if info == nil {
 					fmt.Printf("Error: box \"%s\" not found on disk\n", path)
 					os.Exit(1)
 				}
+				filename := strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(path, boxPath)), "/")
+				if filter.skip(filename, info.IsDir()) {
+					verbosef("skipping %s\n", path)
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
 				// create zipFilename
-				zipFileName := filepath.Join(appendedBoxName, strings.TrimPrefix(path, boxPath))
+				zipFileName := boxDir + filepath.ToSlash(strings.TrimPrefix(path, boxPath))
 				// write directories as empty file with comment "dir"
 				if info.IsDir() {
 					header := &zip.FileHeader{
-						Name:     zipFileName,
-						Comment:  "dir",
+						Name:    zipFileName,
+						Comment: archive.DirAttr,
 					}
-					header.SetModTime(info.ModTime())
+					header.SetModTime(recordedTime(info.ModTime(), modTime))
 					_, err := zipWriter.CreateHeader(header)
 					if err != nil {
 						fmt.Printf("Error creating dir in tmp zip: %s\n", err)
@@ -107,6 +221,44 @@ func operationAppend(pkgs []*build.Package) {
 					os.Exit(1)
 				}
 				zipFileHeader.Name = zipFileName
+				if !modTime.IsZero() {
+					zipFileHeader.SetModTime(modTime)
+				}
+
+				if encryptionKey != nil {
+					// encrypted data doesn't compress
+					zipFileHeader.Method = zip.Store
+					zipFileHeader.Comment = archive.EncryptedAttr
+					content, err := ioutil.ReadFile(path)
+					if err != nil {
+						fmt.Printf("Error reading file to append: %s\n", err)
+						os.Exit(1)
+					}
+					fileName := strings.TrimPrefix(zipFileName, boxDir+"/")
+					content, err = encryption.Encrypt(encryptionKey, fileName, content)
+					if err != nil {
+						fmt.Printf("Error encrypting file to append: %s\n", err)
+						os.Exit(1)
+					}
+					zipFileWriter, err := zipWriter.CreateHeader(zipFileHeader)
+					if err != nil {
+						fmt.Printf("Error creating file in tmp zip: %s\n", err)
+						os.Exit(1)
+					}
+					_, err = zipFileWriter.Write(content)
+					if err != nil {
+						fmt.Printf("Error writing file contents to zip: %s\n", err)
+						os.Exit(1)
+					}
+					return nil
+				}
+
+				hash, err := hashFile(path)
+				if err != nil {
+					fmt.Printf("Error hashing file to append: %s\n", err)
+					os.Exit(1)
+				}
+				zipFileHeader.Comment = archive.HashAttr + "=" + hash
 				zipFileWriter, err := zipWriter.CreateHeader(zipFileHeader)
 				if err != nil {
 					fmt.Printf("Error creating file in tmp zip: %s\n", err)
@@ -129,31 +281,49 @@ func operationAppend(pkgs []*build.Package) {
 		}
 	}
 
-	err = zipWriter.Close()
-	if err != nil {
-		fmt.Printf("Error closing tmp zipfile: %s\n", err)
-		os.Exit(1)
-	}
+}
 
-	err = tmpZipfile.Sync()
+// copyBundle writes the boxes of a bundle written by rice pack to the zip
+// archive. The files are copied, as their offsets change. The modTime is
+// recorded instead of the times in the bundle when it's set.
+func copyBundle(zipWriter *zip.Writer, bundleName string, modTime time.Time) {
+	rd, err := zip.OpenReader(bundleName)
 	if err != nil {
-		fmt.Printf("Error syncing tmp zipfile: %s\n", err)
-		os.Exit(1)
-	}
-	_, err = tmpZipfile.Seek(0, 0)
-	if err != nil {
-		fmt.Printf("Error seeking tmp zipfile: %s\n", err)
+		fmt.Printf("Error opening bundle: %s\n", err)
 		os.Exit(1)
 	}
-	_, err = binfile.Seek(0, 2)
-	if err != nil {
-		fmt.Printf("Error seeking bin file: %s\n", err)
+	defer rd.Close()
+	if !archive.IsNamespaced(rd.Comment) {
+		fmt.Printf("Error: %s is not a bundle written by rice pack\n", bundleName)
 		os.Exit(1)
 	}
 
-	_, err = io.Copy(binfile, tmpZipfile)
-	if err != nil {
-		fmt.Printf("Error appending zipfile to executable: %s\n", err)
-		os.Exit(1)
+	for _, f := range rd.File {
+		verbosef("copying %s\n", f.Name)
+		header := f.FileHeader
+		// the writer adds the timestamps again
+		header.Extra = nil
+		if !modTime.IsZero() {
+			header.SetModTime(modTime)
+		}
+		zipFileWriter, err := zipWriter.CreateHeader(&header)
+		if err != nil {
+			fmt.Printf("Error creating file in tmp zip: %s\n", err)
+			os.Exit(1)
+		}
+		if archive.HasAttr(f.Comment, archive.DirAttr) {
+			continue
+		}
+		srcFile, err := f.Open()
+		if err != nil {
+			fmt.Printf("Error opening file in bundle: %s\n", err)
+			os.Exit(1)
+		}
+		_, err = io.Copy(zipFileWriter, srcFile)
+		if err != nil {
+			fmt.Printf("Error copying file contents to zip: %s\n", err)
+			os.Exit(1)
+		}
+		srcFile.Close()
 	}
 }
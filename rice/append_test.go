@@ -0,0 +1,101 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"go/build"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteBoxesReproducible(t *testing.T) {
+	sourceFiles := []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package main
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func main() {
+	rice.MustFindBox("foo")
+	rice.MustFindBox("bar")
+}
+`),
+		},
+		{"foo/test1.txt", []byte(`This is test 1`)},
+		{"foo/sub/test2.txt", []byte(`This is test 2`)},
+		{"bar/test3.txt", []byte(`This is test 3`)},
+	}
+	pkg, cleanup, err := setUpTestPkg("foobar", sourceFiles)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Date(2020, 2, 2, 12, 0, 0, 0, time.UTC)
+	writeArchive := func() []byte {
+		buf := new(bytes.Buffer)
+		zipWriter := zip.NewWriter(buf)
+		writeBoxes(zipWriter, []*build.Package{pkg}, nil, modTime, fileRules{})
+		if err := zipWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	first := writeArchive()
+	rd, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range rd.File {
+		names = append(names, f.Name)
+		if !f.Modified.Equal(modTime) {
+			t.Errorf("%s has time %v, expected %v", f.Name, f.Modified, modTime)
+		}
+	}
+	expected := []string{
+		"main/bar", "main/bar/test3.txt",
+		"main/foo", "main/foo/sub", "main/foo/sub/test2.txt", "main/foo/test1.txt",
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, names)
+		}
+	}
+
+	touched := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(pkg.Dir, "foo", "test1.txt"), touched, touched); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if again := writeArchive(); !bytes.Equal(first, again) {
+			t.Fatal("archive differs between runs")
+		}
+	}
+}
+
+func TestParseRecordedTime(t *testing.T) {
+	expected := time.Date(2020, 2, 2, 12, 0, 0, 0, time.UTC)
+	for _, value := range []string{"1580644800", "2020-02-02T12:00:00Z", "2020-02-02T13:00:00+01:00"} {
+		parsed, err := parseRecordedTime(value)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+			continue
+		}
+		if !parsed.Equal(expected) || parsed.Location() != time.UTC {
+			t.Errorf("%s: expected %v, got %v", value, expected, parsed)
+		}
+	}
+	if _, err := parseRecordedTime("yesterday"); err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/encryption"
+)
+
+// generatedBox is a box as registered by a generated rice-box.go, without
+// the times
+type generatedBox struct {
+	namespace string
+	dirs      map[string]bool
+	files     map[string]string // hashes of the contents by file name
+}
+
+// operationCheckEmbedGo compares the rice-box.go of the package with the code
+// that embed-go generates, without writing it. It reports the differences
+// and returns whether the file is up to date.
+func operationCheckEmbedGo(pkg *build.Package, opts embedGoOptions) bool {
+	differences, err := checkBoxesGo(pkg, opts)
+	if err != nil {
+		fmt.Printf("Error checking %s: %s\n", filepath.Join(pkg.Dir, boxFilename), err)
+		os.Exit(1)
+	}
+	if len(differences) == 0 {
+		verbosef("%s is up to date\n", filepath.Join(pkg.Dir, boxFilename))
+		return true
+	}
+	fmt.Printf("%s is out of date, run rice embed-go:\n", filepath.Join(pkg.Dir, boxFilename))
+	for _, difference := range differences {
+		fmt.Printf("\t%s\n", difference)
+	}
+	return false
+}
+
+// checkBoxesGo returns the differences between the rice-box.go of the package
+// and the code that writeBoxesGo generates. Files that only differ in their
+// times are the same, and contents are compared after they are decrypted and
+// decompressed.
+func checkBoxesGo(pkg *build.Package, opts embedGoOptions) ([]string, error) {
+	var generated bytes.Buffer
+	err := writeBoxesGo(pkg, &generated, opts)
+	if err != nil && err != errEmptyBox {
+		return nil, err
+	}
+	empty := err == errEmptyBox
+
+	existing, err := ioutil.ReadFile(filepath.Join(pkg.Dir, boxFilename))
+	if os.IsNotExist(err) {
+		if empty {
+			return nil, nil
+		}
+		return []string{boxFilename + " is missing"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if empty {
+		return []string{boxFilename + " exists, but the package has no boxes"}, nil
+	}
+	if bytes.Equal(generated.Bytes(), existing) {
+		return nil, nil
+	}
+
+	expected, err := parseBoxesGo(generated.Bytes(), opts.encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading generated code: %v", err)
+	}
+	actual, err := parseBoxesGo(existing, opts.encryptionKey)
+	if err != nil {
+		return []string{fmt.Sprintf("%s can't be read: %v", boxFilename, err)}, nil
+	}
+	return diffBoxes(expected, actual), nil
+}
+
+// diffBoxes returns the differences between the boxes that are expected and
+// the actual boxes, in sorted order
+func diffBoxes(expected, actual map[string]*generatedBox) []string {
+	names := make(map[string]bool)
+	for name := range expected {
+		names[name] = true
+	}
+	for name := range actual {
+		names[name] = true
+	}
+
+	var differences []string
+	for _, name := range sortedNames(names) {
+		e, a := expected[name], actual[name]
+		switch {
+		case a == nil:
+			differences = append(differences, fmt.Sprintf("box %q is missing", name))
+			continue
+		case e == nil:
+			differences = append(differences, fmt.Sprintf("box %q is no longer used", name))
+			continue
+		case e.namespace != a.namespace:
+			differences = append(differences, fmt.Sprintf("box %q is registered for package %q instead of %q", name, a.namespace, e.namespace))
+		}
+
+		dirs := make(map[string]bool)
+		for dir := range e.dirs {
+			dirs[dir] = true
+		}
+		for dir := range a.dirs {
+			dirs[dir] = true
+		}
+		for _, dir := range sortedNames(dirs) {
+			if !a.dirs[dir] {
+				differences = append(differences, fmt.Sprintf("box %q: dir %q is added", name, dir))
+			} else if !e.dirs[dir] {
+				differences = append(differences, fmt.Sprintf("box %q: dir %q is removed", name, dir))
+			}
+		}
+
+		files := make(map[string]bool)
+		for file := range e.files {
+			files[file] = true
+		}
+		for file := range a.files {
+			files[file] = true
+		}
+		for _, file := range sortedNames(files) {
+			expectedHash, inExpected := e.files[file]
+			actualHash, inActual := a.files[file]
+			switch {
+			case !inActual:
+				differences = append(differences, fmt.Sprintf("box %q: file %q is added", name, file))
+			case !inExpected:
+				differences = append(differences, fmt.Sprintf("box %q: file %q is removed", name, file))
+			case expectedHash != actualHash:
+				differences = append(differences, fmt.Sprintf("box %q: file %q is changed", name, file))
+			}
+		}
+	}
+	return differences
+}
+
+// parseBoxesGo reads the boxes that a generated rice-box.go registers. The
+// content of every file is decrypted with the key and decompressed, to hash
+// what the file holds rather than how it's stored.
+func parseBoxesGo(src []byte, encryptionKey []byte) (map[string]*generatedBox, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), boxFilename, src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	boxes := make(map[string]*generatedBox)
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Name.Name != "init" || fn.Body == nil {
+			continue
+		}
+		// every box is registered by an init function of its own
+		box := &generatedBox{
+			dirs:  make(map[string]bool),
+			files: make(map[string]string),
+		}
+		var name string
+		ast.Inspect(fn.Body, func(node ast.Node) bool {
+			lit, ok := node.(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+			sel, ok := lit.Type.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			fields := literalFields(lit)
+			switch sel.Sel.Name {
+			case "EmbeddedBox":
+				name, box.namespace = fields["Name"], fields["Package"]
+			case "EmbeddedDir":
+				box.dirs[fields["Filename"]] = true
+			case "EmbeddedFile":
+				content := []byte(fields["Content"])
+				if fields["Encrypted"] == "true" {
+					if encryptionKey == nil {
+						err = fmt.Errorf("file %q is encrypted, the key is required to compare it", fields["Filename"])
+						return false
+					}
+					if content, err = encryption.Decrypt(encryptionKey, fields["Filename"], content); err != nil {
+						err = fmt.Errorf("file %q: %v", fields["Filename"], err)
+						return false
+					}
+				}
+				if fields["Compressed"] == "true" {
+					if content, err = embedded.Gunzip(content); err != nil {
+						err = fmt.Errorf("file %q: %v", fields["Filename"], err)
+						return false
+					}
+				}
+				hash := sha256.Sum256(content)
+				box.files[fields["Filename"]] = hex.EncodeToString(hash[:])
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			boxes[name] = box
+		}
+	}
+	return boxes, nil
+}
+
+// literalFields returns the string and boolean fields of a composite literal
+// by name, e.g. Filename: "a.txt" and Content: string("...")
+func literalFields(lit *ast.CompositeLit) map[string]string {
+	fields := make(map[string]string)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		value := kv.Value
+		// the content is converted to a string, e.g. string("...")
+		if call, ok := value.(*ast.CallExpr); ok && len(call.Args) == 1 {
+			value = call.Args[0]
+		}
+		switch v := value.(type) {
+		case *ast.BasicLit:
+			if v.Kind == token.STRING {
+				if s, err := strconv.Unquote(v.Value); err == nil {
+					fields[key.Name] = s
+				}
+			}
+		case *ast.Ident:
+			fields[key.Name] = v.Name
+		}
+	}
+	return fields
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCheckBoxesGo(t *testing.T) {
+	sourceFiles := []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package main
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func main() {
+	rice.MustFindBox("foo")
+}
+`),
+		},
+		{"foo/test1.txt", []byte(`This is test 1`)},
+		{"foo/test2.txt", []byte(`This is test 2`)},
+		{"foo/sub/test3.txt", []byte(`This is test 3`)},
+	}
+
+	cases := map[string]embedGoOptions{
+		"plain":                {},
+		"compressed+encrypted": {compress: true, encryptionKey: bytes.Repeat([]byte{42}, 32)},
+	}
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			pkg, cleanup, err := setUpTestPkg("foobar", sourceFiles)
+			defer cleanup()
+			if err != nil {
+				t.Fatal(err)
+			}
+			check := func(expected ...string) {
+				t.Helper()
+				differences, err := checkBoxesGo(pkg, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(differences, expected) {
+					t.Errorf("expected differences %q, got %q", expected, differences)
+				}
+			}
+
+			check("rice-box.go is missing")
+
+			var buffer bytes.Buffer
+			if err := writeBoxesGo(pkg, &buffer, opts); err != nil {
+				t.Fatal(err)
+			}
+			boxFile := filepath.Join(pkg.Dir, boxFilename)
+			if err := ioutil.WriteFile(boxFile, buffer.Bytes(), 0660); err != nil {
+				t.Fatal(err)
+			}
+			check()
+
+			// other times are not a difference
+			touched := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(pkg.Dir, "foo", "test1.txt"), touched, touched); err != nil {
+				t.Fatal(err)
+			}
+			check()
+
+			if err := ioutil.WriteFile(filepath.Join(pkg.Dir, "foo", "test1.txt"), []byte("changed"), 0660); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(filepath.Join(pkg.Dir, "foo", "sub", "test3.txt")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(filepath.Join(pkg.Dir, "foo", "new"), 0770); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(pkg.Dir, "foo", "new", "test4.txt"), nil, 0660); err != nil {
+				t.Fatal(err)
+			}
+			check(
+				`box "foo": dir "new" is added`,
+				`box "foo": file "new/test4.txt" is added`,
+				`box "foo": file "sub/test3.txt" is removed`,
+				`box "foo": file "test1.txt" is changed`,
+			)
+
+			content, err := ioutil.ReadFile(boxFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(content, buffer.Bytes()) {
+				t.Error("rice-box.go was written by the check")
+			}
+		})
+	}
+}
+
+func TestDiffBoxes(t *testing.T) {
+	box := func(namespace string) *generatedBox {
+		return &generatedBox{
+			namespace: namespace,
+			dirs:      map[string]bool{"": true},
+			files:     map[string]string{"a.txt": "hash"},
+		}
+	}
+	expected := map[string]*generatedBox{"kept": box("main"), "new": box("main")}
+	actual := map[string]*generatedBox{"kept": box("example.com/cmd"), "old": box("main")}
+	differences := diffBoxes(expected, actual)
+	want := []string{
+		`box "kept" is registered for package "example.com/cmd" instead of "main"`,
+		`box "new" is missing`,
+		`box "old" is no longer used`,
+	}
+	if !reflect.DeepEqual(differences, want) {
+		t.Errorf("expected differences %q, got %q", want, differences)
+	}
+}
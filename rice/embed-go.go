@@ -3,15 +3,23 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"go/build"
 	"go/format"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/GeertJohan/go.rice/internal/encryption"
 )
 
 const boxFilename = "rice-box.go"
@@ -20,8 +28,17 @@ const boxFilename = "rice-box.go"
 // are found in the package.
 var errEmptyBox = errors.New("no calls to rice.FindBox() found")
 
-func writeBoxesGo(pkg *build.Package, out io.Writer) error {
-	boxMap := findBoxes(pkg)
+// embedGoOptions holds the options for the code generated by embed-go
+type embedGoOptions struct {
+	encryptionKey []byte    // encrypt the files with this key, when set
+	compress      bool      // gzip compress the files (before encrypting)
+	modTime       time.Time // record this time instead of modification times, when set
+	rules         fileRules // leave out the files of boxes that these rules skip
+}
+
+func writeBoxesGo(pkg *build.Package, out io.Writer, opts embedGoOptions) error {
+	scanned := scanPackage(pkg)
+	boxMap := scanned.boxes
 
 	if len(boxMap) == 0 {
 		return errEmptyBox
@@ -32,7 +49,11 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 
 	var boxes []*boxDataType
 
-	for boxname := range boxMap {
+	// the contents to inject by key. A file on disk is in more than one box
+	// when boxes are nested, its content is encrypted for every box.
+	contents := make(map[string]*embeddedContent)
+
+	for _, boxname := range sortedNames(boxMap) {
 		// find path and filename for this box
 		boxPath := filepath.Join(pkg.Dir, boxname)
 
@@ -55,9 +76,10 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 		}
 
 		// create box datastructure (used by template)
+		ids := &identifiers{}
 		box := &boxDataType{
 			BoxName: boxname,
-			UnixNow: boxInfo.ModTime().Unix(),
+			UnixNow: recordedTime(boxInfo.ModTime(), opts.modTime).Unix(),
 			Files:   make([]*fileDataType, 0),
 			Dirs:    make(map[string]*dirDataType),
 		}
@@ -67,8 +89,13 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 				boxname, boxPath)
 		}
 
+		filter, err := opts.rules.filterForBox(boxPath)
+		if err != nil {
+			return err
+		}
+
 		// fill box datastructure with file data
-		err := filepath.Walk(boxPath, func(path string, info os.FileInfo, err error) error {
+		err = filepath.Walk(boxPath, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return fmt.Errorf("error walking box: %s", err)
 			}
@@ -76,11 +103,18 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 			filename := strings.TrimPrefix(path, boxPath)
 			filename = strings.Replace(filename, "\\", "/", -1)
 			filename = strings.TrimPrefix(filename, "/")
+			if filter.skip(filename, info.IsDir()) {
+				verbosef("\tskipping: '%s'\n", filename)
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 			if info.IsDir() {
 				dirData := &dirDataType{
-					Identifier: "dir" + nextIdentifier(),
+					Identifier: "dir" + ids.next(),
 					FileName:   filename,
-					ModTime:    info.ModTime().Unix(),
+					ModTime:    recordedTime(info.ModTime(), opts.modTime).Unix(),
 					ChildFiles: make([]*fileDataType, 0),
 					ChildDirs:  make([]*dirDataType, 0),
 				}
@@ -93,18 +127,40 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 					parentDir := box.Dirs[strings.Join(pathParts[:len(pathParts)-1], "/")]
 					parentDir.ChildDirs = append(parentDir.ChildDirs, dirData)
 				}
-			} else if !generated(filename) {
+			} else {
 				fileData := &fileDataType{
-					Identifier: "file" + nextIdentifier(),
+					Identifier: "file" + ids.next(),
 					FileName:   filename,
-					ModTime:    info.ModTime().Unix(),
+					ModTime:    recordedTime(info.ModTime(), opts.modTime).Unix(),
+					Encrypted:  opts.encryptionKey != nil,
 				}
 				verbosef("\tincludes file: '%s'\n", fileData.FileName)
 
 				// Instead of injecting content, inject placeholder for fasttemplate.
 				// This allows us to stream the content into the final file,
 				// and it also avoids running gofmt on a very large source code.
-				fileData.Path = path
+				content := &embeddedContent{path: path, name: filename}
+				fileData.ContentKey = strconv.Itoa(len(contents))
+				contents[fileData.ContentKey] = content
+				// the hash would reveal the content of encrypted files
+				if opts.encryptionKey == nil {
+					fileData.Hash, err = hashFile(path)
+					if err != nil {
+						return err
+					}
+				}
+				if opts.compress {
+					compressed, err := gzipFile(path)
+					if err != nil {
+						return err
+					}
+					// keep files that don't compress (e.g. images) as is
+					if int64(len(compressed)) < info.Size() {
+						fileData.Compressed = true
+						fileData.UncompressedSize = info.Size()
+						content.compressed = compressed
+					}
+				}
 				box.Files = append(box.Files, fileData)
 
 				// add tree entry
@@ -129,7 +185,7 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 	// execute template to buffer
 	err := tmplEmbeddedBox.Execute(
 		embedSourceUnformated,
-		embedFileDataType{pkg.Name, boxes},
+		embedFileDataType{pkg.Name, boxNamespace(pkg), scanned.ricePath, boxes},
 	)
 	if err != nil {
 		return fmt.Errorf("error writing embedded box to file (template execute): %s", err)
@@ -143,7 +199,32 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 
 	// write source to file
 	bufWriter := bufio.NewWriterSize(out, 100*1024)
-	err = embeddedBoxFasttemplate(bufWriter, string(embedSource))
+	openContent := func(key string) (io.ReadCloser, error) {
+		c := contents[key]
+		if c == nil {
+			return nil, fmt.Errorf("no content for key %q", key)
+		}
+		content := c.compressed
+		if content == nil && opts.encryptionKey == nil {
+			return os.Open(c.path)
+		}
+		if content == nil {
+			var err error
+			content, err = ioutil.ReadFile(c.path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if opts.encryptionKey != nil {
+			var err error
+			content, err = encryption.Encrypt(opts.encryptionKey, c.name, content)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+	err = embeddedBoxFasttemplate(bufWriter, string(embedSource), openContent)
 	if err != nil {
 		return fmt.Errorf("error writing embedSource to file: %s\n", err)
 	}
@@ -154,7 +235,52 @@ func writeBoxesGo(pkg *build.Package, out io.Writer) error {
 	return nil
 }
 
-func operationEmbedGo(pkg *build.Package) {
+// embeddedContent is the content of a file in a box, that is injected into
+// the generated code
+type embeddedContent struct {
+	path       string // on disk
+	name       string // in the box, the additional data when encrypting
+	compressed []byte // gzip compressed content, when the file is compressed
+}
+
+// gzipFile returns the gzip compressed content of a file
+func gzipFile(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(zw, f); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hashFile returns the hex encoded SHA-256 of the content of a file
+func hashFile(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func operationEmbedGo(pkg *build.Package, opts embedGoOptions) {
 	// create go file for box
 	boxFile, err := os.Create(filepath.Join(pkg.Dir, boxFilename))
 	if err != nil {
@@ -162,7 +288,7 @@ func operationEmbedGo(pkg *build.Package) {
 		os.Exit(1)
 	}
 
-	err = writeBoxesGo(pkg, boxFile)
+	err = writeBoxesGo(pkg, boxFile, opts)
 	boxFile.Close()
 	if err != nil {
 		// don't leave an invalid go file in the package directory.
@@ -176,7 +302,7 @@ func operationEmbedGo(pkg *build.Package) {
 			// notify user when no calls to rice.FindBox are made,
 			// but don't fail, since it's useful to be able to run
 			// go.rice unconditionally.
-			log.Println(errEmptyBox)
+			log.Printf("%s: %s\n", pkg.ImportPath, errEmptyBox)
 		}
 	}
 }
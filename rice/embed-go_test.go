@@ -2,8 +2,11 @@ package main
 
 import (
 	"bytes"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEmbedGo(t *testing.T) {
@@ -96,7 +99,7 @@ func main() {
 
 	var buffer bytes.Buffer
 
-	err = writeBoxesGo(pkg, &buffer)
+	err = writeBoxesGo(pkg, &buffer, embedGoOptions{})
 	if err != nil {
 		t.Error(err)
 		return
@@ -104,7 +107,7 @@ func main() {
 
 	t.Logf("Generated file: \n%s", buffer.String())
 
-	validateBoxFile(t, filepath.Join(pkg.Dir, "rice-box.go"), &buffer, sourceFiles)
+	validateBoxFile(t, filepath.Join(pkg.Dir, "rice-box.go"), &buffer, sourceFiles, embedGoOptions{})
 }
 
 func TestEmbedGoEmpty(t *testing.T) {
@@ -127,9 +130,210 @@ func main() {
 
 	var buffer bytes.Buffer
 
-	err = writeBoxesGo(pkg, &buffer)
+	err = writeBoxesGo(pkg, &buffer, embedGoOptions{})
 	if err != errEmptyBox {
 		t.Errorf("expected errEmptyBox, got %v", err)
 		return
 	}
 }
+
+func TestEmbedGoEncrypted(t *testing.T) {
+	sourceFiles := []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package main
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func main() {
+	rice.MustFindBox("foo")
+}
+`),
+		},
+		{
+			"foo/test1.txt",
+			[]byte(`This is test 1`),
+		},
+		{
+			"foo/bar/test1.txt",
+			[]byte(`This is test 1 in bar`),
+		},
+		{
+			"foo/empty.txt",
+			[]byte{},
+		},
+	}
+	pkg, cleanup, err := setUpTestPkg("foobar", sourceFiles)
+	defer cleanup()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	opts := embedGoOptions{encryptionKey: bytes.Repeat([]byte{42}, 32)}
+	var buffer bytes.Buffer
+	err = writeBoxesGo(pkg, &buffer, opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytes.Contains(buffer.Bytes(), []byte("test 1 in bar")) {
+		t.Error("generated file contains plain text content")
+	}
+
+	validateBoxFile(t, filepath.Join(pkg.Dir, "rice-box.go"), &buffer, sourceFiles, opts)
+}
+
+func TestEmbedGoEncryptedNestedBoxes(t *testing.T) {
+	sourceFiles := []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package main
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func main() {
+	rice.MustFindBox("foo")
+	rice.MustFindBox("foo/bar")
+}
+`),
+		},
+		{
+			"foo/bar/test1.txt",
+			bytes.Repeat([]byte("This is test 1 in bar. "), 100),
+		},
+	}
+	pkg, cleanup, err := setUpTestPkg("foobar", sourceFiles)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is in both boxes, under another name, so it's encrypted with
+	// other additional data for each box
+	for _, compress := range []bool{false, true} {
+		opts := embedGoOptions{encryptionKey: bytes.Repeat([]byte{42}, 32), compress: compress}
+		var buffer bytes.Buffer
+		if err := writeBoxesGo(pkg, &buffer, opts); err != nil {
+			t.Fatal(err)
+		}
+		boxes, err := parseBoxesGo(buffer.Bytes(), opts.encryptionKey)
+		if err != nil {
+			t.Fatalf("compress %v: %v", compress, err)
+		}
+		outer, inner := boxes["foo"].files["bar/test1.txt"], boxes["foo/bar"].files["test1.txt"]
+		if outer == "" || outer != inner {
+			t.Errorf("compress %v: expected the same content in both boxes, got hashes %q and %q", compress, outer, inner)
+		}
+	}
+}
+
+func TestEmbedGoCompressed(t *testing.T) {
+	sourceFiles := []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package main
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func main() {
+	rice.MustFindBox("foo")
+}
+`),
+		},
+		{
+			"foo/compresses.txt",
+			bytes.Repeat([]byte("This compresses well. "), 100),
+		},
+		{
+			"foo/bar/small.txt",
+			[]byte(`too small to compress`),
+		},
+	}
+
+	cases := map[string]embedGoOptions{
+		"compressed":           {compress: true},
+		"compressed+encrypted": {compress: true, encryptionKey: bytes.Repeat([]byte{42}, 32)},
+	}
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			pkg, cleanup, err := setUpTestPkg("foobar", sourceFiles)
+			defer cleanup()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buffer bytes.Buffer
+			err = writeBoxesGo(pkg, &buffer, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buffer.Len() > 2000 {
+				t.Errorf("generated file is %d bytes, expected the content to be compressed", buffer.Len())
+			}
+			if got := strings.Count(buffer.String(), "Compressed:"); got != 1 {
+				t.Errorf("%d files are compressed, expected only compresses.txt", got)
+			}
+
+			validateBoxFile(t, filepath.Join(pkg.Dir, "rice-box.go"), &buffer, sourceFiles, opts)
+		})
+	}
+}
+
+func TestEmbedGoReproducible(t *testing.T) {
+	sourceFiles := []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package main
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func main() {
+	rice.MustFindBox("foo")
+	rice.MustFindBox("other")
+	rice.MustFindBox("zoo")
+}
+`),
+		},
+		{"foo/test1.txt", []byte(`This is test 1`)},
+		{"foo/sub/test2.txt", []byte(`This is test 2`)},
+		{"other/test3.txt", []byte(`This is test 3`)},
+		{"zoo/test4.txt", []byte(`This is test 4`)},
+	}
+	pkg, cleanup, err := setUpTestPkg("foobar", sourceFiles)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := embedGoOptions{modTime: time.Date(2020, 2, 2, 12, 0, 0, 0, time.UTC)}
+	var first bytes.Buffer
+	if err := writeBoxesGo(pkg, &first, opts); err != nil {
+		t.Fatal(err)
+	}
+	validateBoxFile(t, filepath.Join(pkg.Dir, "rice-box.go"), bytes.NewReader(first.Bytes()), sourceFiles, opts)
+
+	// neither the modification times on disk nor the order in which boxes
+	// are found changes the generated code
+	touched := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(pkg.Dir, "foo", "sub", "test2.txt"), touched, touched); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		var again bytes.Buffer
+		if err := writeBoxesGo(pkg, &again, opts); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first.Bytes(), again.Bytes()) {
+			t.Fatalf("generated code differs:\n%s\n\n%s", first.String(), again.String())
+		}
+	}
+}
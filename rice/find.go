@@ -4,13 +4,32 @@ import (
 	"fmt"
 	"go/ast"
 	"go/build"
+	"go/constant"
 	"go/parser"
 	"go/token"
+	"go/types"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// defaultRiceImportPath is the import path of the go.rice package, generated
+// code imports the embedded package from it when the scanned package doesn't
+// import go.rice under another path
+const defaultRiceImportPath = "github.com/GeertJohan/go.rice"
+
+// riceImportPathPattern matches the import paths of go.rice, its forks and
+// its major versions, e.g. "github.com/user/go.rice/v2"
+var riceImportPathPattern = regexp.MustCompile(`(^|/)go\.rice(/v[0-9]+)?$`)
+
+// majorVersionPattern matches the last element of the import path of a
+// major version, e.g. "v2"
+var majorVersionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
 func badArgument(fileset *token.FileSet, p token.Pos) {
 	pos := fileset.Position(p)
 	filename := pos.Filename
@@ -22,21 +41,50 @@ func badArgument(fileset *token.FileSet, p token.Pos) {
 		}
 	}
 	msg := fmt.Sprintf("%s:%d: Error: found call to rice.FindBox, "+
-		"but argument must be a string literal.\n", filename, pos.Line)
+		"but argument must be a constant string.\n"+
+		"Constants of packages that go list can't load are unknown, "+
+		"run with -v to see the packages that are not loaded.\n", filename, pos.Line)
 	fmt.Println(msg)
 	os.Exit(1)
 }
 
+// scannedPackage holds what findBoxes found in a package
+type scannedPackage struct {
+	boxes    map[string]bool // names of the boxes
+	ricePath string          // import path of go.rice, as imported by the package
+}
+
 func findBoxes(pkg *build.Package) map[string]bool {
-	// create map of boxes to embed
-	var boxMap = make(map[string]bool)
+	return scanPackage(pkg).boxes
+}
+
+// sortedNames returns the names in the set in sorted order, e.g. so the
+// generated code and archives are the same for every run
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scanPackage type-checks the package and finds the calls to FindBox and
+// MustFindBox of go.rice, as functions or as methods of rice.Config, with a
+// constant box name.
+func scanPackage(pkg *build.Package) *scannedPackage {
+	scanned := &scannedPackage{
+		boxes:    make(map[string]bool),
+		ricePath: defaultRiceImportPath,
+	}
 
 	// create one list of files for this package
 	filenames := make([]string, 0, len(pkg.GoFiles)+len(pkg.CgoFiles))
 	filenames = append(filenames, pkg.GoFiles...)
 	filenames = append(filenames, pkg.CgoFiles...)
 
-	// loop over files, search for rice.FindBox(..) calls
+	fset := token.NewFileSet()
+	var files []*ast.File
 	for _, filename := range filenames {
 		// find full filepath
 		fullpath := filepath.Join(pkg.Dir, filename)
@@ -47,102 +95,285 @@ func findBoxes(pkg *build.Package) map[string]bool {
 		}
 		verbosef("scanning file %q\n", fullpath)
 
-		fset := token.NewFileSet()
 		f, err := parser.ParseFile(fset, fullpath, nil, 0)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		files = append(files, f)
 
-		var riceIsImported bool
-		ricePkgName := "rice"
-		for _, imp := range f.Imports {
-			if strings.HasSuffix(imp.Path.Value, "go.rice\"") {
-				if imp.Name != nil {
-					ricePkgName = imp.Name.Name
-				}
-				riceIsImported = true
-				break
+		for _, spec := range f.Imports {
+			if importPath, err := strconv.Unquote(spec.Path.Value); err == nil && isRiceImportPath(importPath) {
+				scanned.ricePath = importPath
 			}
 		}
-		if !riceIsImported {
-			// Rice wasn't imported, so we won't find a box.
-			continue
-		}
-		if ricePkgName == "_" {
-			// Rice pkg is unnamed, so we won't find a box.
-			continue
-		}
+	}
 
-		// Inspect AST, looking for calls to (Must)?FindBox.
-		// First parameter of the func must be a basic literal.
-		// Identifiers won't be resolved.
-		var nextIdentIsBoxFunc bool
-		var nextBasicLitParamIsBoxName bool
-		var boxCall token.Pos
-		var validVariablesForBoxes = make(map[string]bool)
+	imp := &scanImporter{
+		fset:     fset,
+		srcDir:   pkg.Dir,
+		packages: make(map[string]*types.Package),
+		dirs:     make(map[string]*listedPackage),
+	}
+	if listed := listedPackages[pkg.ImportPath]; listed != nil && listed.Dir == pkg.Dir {
+		imp.dirs[pkg.Dir] = listed
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{
+		Importer:    imp,
+		FakeImportC: true,
+		// only the calls to go.rice matter, the rest of the package doesn't
+		// type-check without the rest of go.rice
+		Error: func(error) {},
+	}
+	conf.Check(pkg.ImportPath, fset, files, info)
 
+	// variables that hold FindBox or MustFindBox, e.g. find := conf.MustFindBox
+	boxFuncVars := make(map[types.Object]bool)
+	isBoxFunc := func(expr ast.Expr) bool {
+		switch x := unparen(expr).(type) {
+		case *ast.Ident:
+			obj := info.Uses[x]
+			return isRiceBoxFunc(obj) || boxFuncVars[obj]
+		case *ast.SelectorExpr:
+			obj := info.Uses[x.Sel]
+			return isRiceBoxFunc(obj) || boxFuncVars[obj]
+		}
+		return false
+	}
+	for _, f := range files {
 		ast.Inspect(f, func(node ast.Node) bool {
-			if node == nil {
-				return false
-			}
 			switch x := node.(type) {
-			// this case fixes the var := func() style assignments, not assignments to vars declared separately from the assignment.
 			case *ast.AssignStmt:
-				var assign = node.(*ast.AssignStmt)
-				name, found := assign.Lhs[0].(*ast.Ident)
-				if found {
-					composite, first := assign.Rhs[0].(*ast.CompositeLit)
-					if first {
-						riceSelector, second := composite.Type.(*ast.SelectorExpr)
-
-						if second {
-							callCorrect := riceSelector.Sel.Name == "Config"
-							packageName, third := riceSelector.X.(*ast.Ident)
-
-							if third && callCorrect && packageName.Name == ricePkgName {
-								validVariablesForBoxes[name.Name] = true
-								verbosef("\tfound variable, saving to scan for boxes: %q\n", name.Name)
-							}
+				if len(x.Lhs) == len(x.Rhs) {
+					for i, rhs := range x.Rhs {
+						if ident, ok := x.Lhs[i].(*ast.Ident); ok && isBoxFunc(rhs) {
+							boxFuncVars[objectOf(info, ident)] = true
 						}
 					}
 				}
-			case *ast.Ident:
-				if nextIdentIsBoxFunc || ricePkgName == "." {
-					nextIdentIsBoxFunc = false
-					if x.Name == "FindBox" || x.Name == "MustFindBox" {
-						nextBasicLitParamIsBoxName = true
-						boxCall = x.Pos()
-					}
-				} else {
-					if x.Name == ricePkgName || validVariablesForBoxes[x.Name] {
-						nextIdentIsBoxFunc = true
-					}
-				}
-			case *ast.BasicLit:
-				if nextBasicLitParamIsBoxName {
-					if x.Kind == token.STRING {
-						nextBasicLitParamIsBoxName = false
-						// trim "" or ``
-						name := x.Value[1 : len(x.Value)-1]
-						boxMap[name] = true
-						verbosef("\tfound box %q\n", name)
-					} else {
-						badArgument(fset, boxCall)
+			case *ast.ValueSpec:
+				if len(x.Names) == len(x.Values) {
+					for i, value := range x.Values {
+						if isBoxFunc(value) {
+							boxFuncVars[info.Defs[x.Names[i]]] = true
+						}
 					}
 				}
+			}
+			return true
+		})
+	}
 
-			default:
-				if nextIdentIsBoxFunc {
-					nextIdentIsBoxFunc = false
-				}
-				if nextBasicLitParamIsBoxName {
-					badArgument(fset, boxCall)
+	for _, f := range files {
+		ast.Inspect(f, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || !isBoxFunc(call.Fun) {
+				return true
+			}
+			// a method expression, e.g. (*rice.Config).FindBox(conf, "name"),
+			// takes the config as first argument
+			arg := 0
+			if sel, ok := unparen(call.Fun).(*ast.SelectorExpr); ok {
+				if selection := info.Selections[sel]; selection != nil && selection.Kind() == types.MethodExpr {
+					arg = 1
 				}
 			}
+			if len(call.Args) <= arg {
+				badArgument(fset, call.Pos())
+			}
+			value := info.Types[call.Args[arg]].Value
+			if value == nil || value.Kind() != constant.String {
+				badArgument(fset, call.Pos())
+			}
+			name := constant.StringVal(value)
+			scanned.boxes[name] = true
+			verbosef("\tfound box %q\n", name)
 			return true
 		})
 	}
 
-	return boxMap
+	return scanned
+}
+
+// unparen returns the expression without enclosing parentheses
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
+
+// objectOf returns the object that an identifier defines or refers to
+func objectOf(info *types.Info, ident *ast.Ident) types.Object {
+	if obj := info.Defs[ident]; obj != nil {
+		return obj
+	}
+	return info.Uses[ident]
+}
+
+// isRiceBoxFunc returns whether the object is the FindBox or MustFindBox
+// function of go.rice, or the method of rice.Config
+func isRiceBoxFunc(obj types.Object) bool {
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() == nil || !isRiceImportPath(fn.Pkg().Path()) {
+		return false
+	}
+	if fn.Name() != "FindBox" && fn.Name() != "MustFindBox" {
+		return false
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return true
+	}
+	recvType := recv.Type()
+	if ptr, ok := recvType.(*types.Pointer); ok {
+		recvType = ptr.Elem()
+	}
+	named, ok := recvType.(*types.Named)
+	return ok && named.Obj().Name() == "Config"
+}
+
+// isRiceImportPath returns whether the import path is that of go.rice
+func isRiceImportPath(importPath string) bool {
+	return riceImportPathPattern.MatchString(importPath)
+}
+
+// scanImporter imports the packages of a scanned package for the type
+// checker. go.rice is replaced by a package that only declares the API that
+// locates boxes. Other packages, including the standard library, are
+// type-checked from source when go list listed them (see listedPackages), so
+// constants declared in them can be used as box names. Packages that weren't
+// listed are empty.
+type scanImporter struct {
+	fset     *token.FileSet
+	srcDir   string
+	packages map[string]*types.Package
+	dirs     map[string]*listedPackage // the imported packages by directory
+}
+
+// checkedPackages holds the packages that scanImporter type-checked from
+// source, so packages that are imported by several scanned packages, such as
+// the standard library, are only checked once
+var checkedPackages = make(map[*listedPackage]*types.Package)
+
+func (imp *scanImporter) Import(importPath string) (*types.Package, error) {
+	return imp.ImportFrom(importPath, imp.srcDir, 0)
+}
+
+func (imp *scanImporter) ImportFrom(importPath, srcDir string, mode types.ImportMode) (*types.Package, error) {
+	// resolve the import path the way go list did, e.g. for vendored packages
+	if importer := imp.dirs[srcDir]; importer != nil {
+		if resolved, ok := importer.ImportMap[importPath]; ok {
+			importPath = resolved
+		}
+	}
+	if pkg := imp.packages[importPath]; pkg != nil {
+		return pkg, nil
+	}
+	var pkg *types.Package
+	switch {
+	case isRiceImportPath(importPath):
+		pkg = newRicePackage(importPath)
+	case importPath == "unsafe":
+		pkg = types.Unsafe
+	default:
+		pkg = imp.importSource(importPath)
+	}
+	imp.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// importSource type-checks the package with the given import path from the
+// source that go list listed, it returns an empty package when the package
+// can't be loaded
+func (imp *scanImporter) importSource(importPath string) *types.Package {
+	// an import cycle is broken with an empty package
+	imp.packages[importPath] = newEmptyPackage(importPath)
+
+	listed := listedPackages[importPath]
+	if listed == nil || listed.Dir == "" {
+		verbosef("\tnot loading package %q: not listed by go list\n", importPath)
+		return newEmptyPackage(importPath)
+	}
+	if pkg := checkedPackages[listed]; pkg != nil {
+		return pkg
+	}
+	imp.dirs[listed.Dir] = listed
+
+	var files []*ast.File
+	filenames := append(append([]string{}, listed.GoFiles...), listed.CgoFiles...)
+	for _, filename := range filenames {
+		f, err := parser.ParseFile(imp.fset, filepath.Join(listed.Dir, filename), nil, 0)
+		if err != nil {
+			verbosef("\tnot loading package %q: %v\n", importPath, err)
+			return newEmptyPackage(importPath)
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{
+		Importer:    imp,
+		FakeImportC: true,
+		// only the declarations are used, and errors are expected as cgo is
+		// faked
+		IgnoreFuncBodies: true,
+		Error:            func(error) {},
+	}
+	pkg, _ := conf.Check(importPath, imp.fset, files, nil)
+	checkedPackages[listed] = pkg
+	return pkg
+}
+
+// newEmptyPackage creates a package that declares nothing, selectors on it
+// are type errors
+func newEmptyPackage(importPath string) *types.Package {
+	name := path.Base(importPath)
+	if majorVersionPattern.MatchString(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	pkg := types.NewPackage(importPath, name)
+	pkg.MarkComplete()
+	return pkg
+}
+
+// newRicePackage creates a go.rice package with the given import path, that
+// declares FindBox, MustFindBox and rice.Config with those methods
+func newRicePackage(importPath string) *types.Package {
+	pkg := types.NewPackage(importPath, "rice")
+	scope := pkg.Scope()
+
+	newType := func(name string) *types.Named {
+		named := types.NewNamed(types.NewTypeName(token.NoPos, pkg, name, nil), types.NewStruct(nil, nil), nil)
+		scope.Insert(named.Obj())
+		return named
+	}
+	box := types.NewPointer(newType("Box"))
+	config := newType("Config")
+
+	errorType := types.Universe.Lookup("error").Type()
+	newSignature := func(recv *types.Var, results ...types.Type) *types.Signature {
+		name := types.NewParam(token.NoPos, pkg, "name", types.Typ[types.String])
+		vars := make([]*types.Var, len(results))
+		for i, result := range results {
+			vars[i] = types.NewParam(token.NoPos, pkg, "", result)
+		}
+		return types.NewSignature(recv, types.NewTuple(name), types.NewTuple(vars...), false)
+	}
+	scope.Insert(types.NewFunc(token.NoPos, pkg, "FindBox", newSignature(nil, box, errorType)))
+	scope.Insert(types.NewFunc(token.NoPos, pkg, "MustFindBox", newSignature(nil, box)))
+
+	recv := types.NewParam(token.NoPos, pkg, "c", types.NewPointer(config))
+	config.AddMethod(types.NewFunc(token.NoPos, pkg, "FindBox", newSignature(recv, box, errorType)))
+	config.AddMethod(types.NewFunc(token.NoPos, pkg, "MustFindBox", newSignature(recv, box)))
+
+	pkg.MarkComplete()
+	return pkg
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"runtime"
 	"testing"
 )
 
@@ -266,3 +267,179 @@ func LoadBoxes3() {
 		}
 	}
 }
+
+func TestFindBoxesFromConstants(t *testing.T) {
+	pkg, cleanup, err := setUpTestPkg("foobar", []sourceFile{
+		{
+			"names.go",
+			[]byte(`package foobar
+
+const (
+	templatesBox = "templates"
+	prefix       = "assets/"
+	cssBox       = prefix + "css"
+)
+
+type boxName string
+
+const typedBox boxName = "typed"
+`),
+		},
+		{
+			"boxes.go",
+			[]byte(`package foobar
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func LoadBoxes() {
+	rice.MustFindBox(templatesBox)
+	rice.MustFindBox(cssBox)
+	rice.MustFindBox(prefix + "js")
+	rice.MustFindBox(string(typedBox))
+	rice.MustFindBox(("parenthesized"))
+}
+`),
+		},
+	})
+	defer cleanup()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectedBoxes := []string{"templates", "assets/css", "assets/js", "typed", "parenthesized"}
+	boxMap := findBoxes(pkg)
+	if err := expectBoxes(expectedBoxes, boxMap); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFindBoxesViaConfigs(t *testing.T) {
+	pkg, cleanup, err := setUpTestPkg("foobar", []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package foobar
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+var conf = rice.Config{
+	LocateOrder: []rice.LocateMethod{rice.LocateEmbedded, rice.LocateFS},
+}
+
+var confPtr = &rice.Config{}
+
+type server struct {
+	boxes  rice.Config
+	nested struct{ conf *rice.Config }
+}
+
+var mustFind = conf.MustFindBox
+
+func LoadBoxes(s *server) {
+	conf.MustFindBox("package-var")
+	confPtr.FindBox("package-pointer")
+	s.boxes.MustFindBox("field")
+	s.nested.conf.FindBox("nested-field")
+
+	find := s.boxes.FindBox
+	find("method-value")
+	mustFind("package-method-value")
+	load := rice.MustFindBox
+	load("function-value")
+	(*rice.Config).MustFindBox(&conf, "method-expression")
+}
+`),
+		},
+	})
+	defer cleanup()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expectedBoxes := []string{
+		"package-var", "package-pointer", "field", "nested-field",
+		"method-value", "package-method-value", "function-value", "method-expression",
+	}
+	boxMap := findBoxes(pkg)
+	if err := expectBoxes(expectedBoxes, boxMap); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFindBoxesOtherImportPaths(t *testing.T) {
+	for _, importPath := range []string{
+		"github.com/GeertJohan/go.rice/v2",
+		"example.com/fork/go.rice",
+	} {
+		pkg, cleanup, err := setUpTestPkg("foobar", []sourceFile{
+			{
+				"boxes.go",
+				[]byte(`package foobar
+
+import (
+	"` + importPath + `"
+)
+
+func LoadBoxes() {
+	rice.MustFindBox("foo")
+	var conf rice.Config
+	conf.FindBox("bar")
+}
+`),
+			},
+		})
+		defer cleanup()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		scanned := scanPackage(pkg)
+		if err := expectBoxes([]string{"foo", "bar"}, scanned.boxes); err != nil {
+			t.Errorf("%s: %v", importPath, err)
+		}
+		if scanned.ricePath != importPath {
+			t.Errorf("expected import path %q, got %q", importPath, scanned.ricePath)
+		}
+	}
+}
+
+func TestFindBoxesFromImportedConstants(t *testing.T) {
+	setOffline(t)
+	dir := setUpTestModule(t, []sourceFile{
+		{"names/names.go", []byte(`package names
+
+import "runtime"
+
+const Templates = "templates-" + runtime.GOARCH
+`)},
+		{"web/web.go", []byte(`package web
+
+import (
+	"runtime"
+
+	"example.com/mono/names"
+	"github.com/GeertJohan/go.rice"
+)
+
+func LoadBoxes() {
+	rice.MustFindBox(names.Templates)
+	rice.MustFindBox("assets-" + runtime.GOOS)
+}
+`)},
+	})
+	pkgs, err := loadPackages(dir, []string{"./web"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedBoxes := []string{"templates-" + runtime.GOARCH, "assets-" + runtime.GOOS}
+	if err := expectBoxes(expectedBoxes, findBoxes(pkgs[0])); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"go/build"
 	"os"
 
 	goflags "github.com/jessevdk/go-flags" // rename import to `goflags` (file scope) so we can use `var flags` (package scope)
@@ -13,17 +12,39 @@ var flags struct {
 	MemProfile  string   `long:"memprofile" description:"Write memory profile to this file"`
 	CpuProfile  string   `long:"cpuprofile" description:"Write cpu profile to this file"`
 	Verbose     bool     `long:"verbose" short:"v" description:"Show verbose debug information"`
-	ImportPaths []string `long:"import-path" short:"i" description:"Import path(s) to use. Using PWD when left empty. Specify multiple times for more import paths to append"`
+	ImportPaths []string `long:"import-path" short:"i" description:"Import path(s) or pattern(s) such as ./... to use, also accepted as arguments. Using PWD when left empty, or $GOPACKAGE in PWD when run by go generate. Specify multiple times for more import paths to append"`
 
 	Append struct {
 		Executable string `long:"exec" description:"Executable to append" required:"true"`
+		SignKey    string `long:"sign-key" description:"Sign the appended archive with the ed25519 private key in this PEM file"`
+		EncryptKey string `long:"encrypt-key" description:"Encrypt the files with the AES key in this file (hex encoded, 16, 24 or 32 bytes)"`
+		Bundle     string `long:"bundle" description:"Append the boxes of this bundle written by rice pack, instead of those of the packages"`
 	} `command:"append"`
 
-	EmbedGo   struct{} `command:"embed-go" alias:"embed"`
+	Pack struct {
+		Output     string `long:"out" short:"o" description:"Bundle file to write, e.g. assets.rice" required:"true"`
+		SignKey    string `long:"sign-key" description:"Sign the bundle with the ed25519 private key in this PEM file"`
+		EncryptKey string `long:"encrypt-key" description:"Encrypt the files with the AES key in this file (hex encoded, 16, 24 or 32 bytes)"`
+	} `command:"pack"`
+
+	Verify struct {
+		Executable string `long:"exec" description:"Executable to verify" required:"true"`
+		PublicKey  string `long:"key" description:"PEM file with the ed25519 public key to verify the appended archive with" required:"true"`
+	} `command:"verify"`
+
+	EmbedGo struct {
+		EncryptKey string `long:"encrypt-key" description:"Encrypt the files with the AES key in this file (hex encoded, 16, 24 or 32 bytes)"`
+		Compress   bool   `long:"compress" description:"Store the files gzip compressed, they are decompressed when first opened"`
+		Check      bool   `long:"check" description:"Check that rice-box.go is up to date instead of writing it, exit with status 1 when it's not"`
+	} `command:"embed-go" alias:"embed"`
 	EmbedSyso struct{} `command:"embed-syso" hidden:"true"`
 	Clean     struct{} `command:"clean"`
 
-	Tags []string `long:"tags" description:"Tags to use with the implicit go build"`
+	Tags         []string `long:"tags" description:"Tags to use with the implicit go build"`
+	ModTime      string   `long:"mod-time" description:"Record this time (unix seconds or RFC 3339) for all boxes, dirs and files instead of their modification times, for reproducible output. Defaults to $SOURCE_DATE_EPOCH"`
+	Exclude      []string `long:"exclude" description:"Leave out the files and dirs in boxes that match this pattern (gitignore syntax, e.g. *.map or node_modules/), in addition to the .riceignore of a box. Specify multiple times for more patterns"`
+	Include      []string `long:"include" description:"Only embed or append the files in boxes that match this pattern (gitignore syntax, e.g. *.html). Specify multiple times for more patterns"`
+	SkipDotfiles bool     `long:"skip-dotfiles" description:"Leave out the files and dirs in boxes with a name that starts with a dot"`
 }
 
 // flags parser
@@ -57,28 +78,24 @@ func parseArguments() {
 		os.Exit(1)
 	}
 
-	// error on left-over arguments
+	// left-over arguments are import paths or patterns, verify and append of
+	// a bundle don't use packages
 	if len(args) > 0 {
-		fmt.Printf("Unexpected arguments: %s\nUse --help to view available options.", args)
-		os.Exit(1)
-	}
-
-	// default ImportPath to pwd when not set
-	if len(flags.ImportPaths) == 0 {
-		pwd, err := os.Getwd()
-		if err != nil {
-			fmt.Printf("error getting pwd: %s\n", err)
+		if skipPackages() {
+			fmt.Printf("Unexpected arguments: %s\nUse --help to view available options.", args)
 			os.Exit(1)
 		}
-		verbosef("using pwd as import path\n")
-		// find non-absolute path for this pwd
-		pkg, err := build.ImportDir(pwd, build.FindOnly)
-		if err != nil {
-			fmt.Printf("error using current directory as import path: %s\n", err)
-			os.Exit(1)
-		}
-		flags.ImportPaths = append(flags.ImportPaths, pkg.ImportPath)
-		verbosef("using import paths: %s\n", flags.ImportPaths)
-		return
+		flags.ImportPaths = append(flags.ImportPaths, args...)
+	}
+}
+
+// skipPackages returns whether the operation doesn't work on packages
+func skipPackages() bool {
+	switch flagsParser.Active.Name {
+	case "verify":
+		return true
+	case "append":
+		return flags.Append.Bundle != ""
 	}
+	return false
 }
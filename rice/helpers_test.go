@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go/ast"
 	"go/build"
@@ -14,6 +16,9 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/GeertJohan/go.rice/embedded"
+	"github.com/GeertJohan/go.rice/internal/encryption"
 )
 
 type sourceFile struct {
@@ -29,14 +34,19 @@ type registeredDir struct {
 }
 
 type registeredFile struct {
-	Filename string
-	ModTime  int
-	Content  string
+	Filename         string
+	ModTime          int
+	Content          string
+	Encrypted        bool
+	Compressed       bool
+	UncompressedSize int
+	Hash             string
 }
 
 type registeredBox struct {
-	Name string
-	Time int
+	Name    string
+	Package string
+	Time    int
 	// key is path
 	Dirs map[string]*registeredDir
 	// key is path
@@ -231,6 +241,29 @@ func parseFile(expr ast.Expr, files map[string]*registeredFile) (*registeredFile
 				if err != nil {
 					errors = append(errors, fmt.Errorf("Content %s", err))
 				}
+			case "Encrypted":
+				if !isIdent("true", el.Value) {
+					errors = append(errors, fmt.Errorf("Encrypted is not true: %#v", el.Value))
+				}
+				ret.Encrypted = true
+			case "Compressed":
+				if !isIdent("true", el.Value) {
+					errors = append(errors, fmt.Errorf("Compressed is not true: %#v", el.Value))
+				}
+				ret.Compressed = true
+			case "UncompressedSize":
+				lit, ok := el.Value.(*ast.BasicLit)
+				if !ok || lit.Kind != token.INT {
+					errors = append(errors, fmt.Errorf("UncompressedSize is not an integer: %#v", el.Value))
+					continue
+				}
+				ret.UncompressedSize, _ = strconv.Atoi(lit.Value)
+			case "Hash":
+				var err error
+				ret.Hash, err = parseString(el.Value)
+				if err != nil {
+					errors = append(errors, fmt.Errorf("Hash %s", err))
+				}
 			default:
 				errors = append(errors, fmt.Errorf("Unknown field: %v: %#v", key, el.Value))
 			}
@@ -267,6 +300,12 @@ func parseRegistration(lit *ast.CompositeLit, dirs map[string]*registeredDir, fi
 				if err != nil {
 					errors = append(errors, fmt.Errorf("Name %s", err))
 				}
+			case "Package":
+				var err error
+				ret.Package, err = parseString(el.Value)
+				if err != nil {
+					errors = append(errors, fmt.Errorf("Package %s", err))
+				}
 			case "Dirs":
 				var errors2 []error
 				ret.Dirs, errors2 = parseDirsMap(el.Value, dirs, files)
@@ -414,7 +453,7 @@ func unpoint(expr ast.Expr) ast.Expr {
 	return expr
 }
 
-func validateBoxFile(t *testing.T, filename string, src io.Reader, sourceFiles []sourceFile) {
+func validateBoxFile(t *testing.T, filename string, src io.Reader, sourceFiles []sourceFile, opts embedGoOptions) {
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, filename, src, 0)
 	if err != nil {
@@ -549,15 +588,21 @@ func validateBoxFile(t *testing.T, filename string, src io.Reader, sourceFiles [
 	}
 
 	// Validate that all boxes are present.
-	if _, ok := boxes["foo"]; !ok {
+	if box, ok := boxes["foo"]; !ok {
 		t.Error("box \"foo\" not found")
+	} else if box.Package != "main" {
+		t.Errorf("box \"foo\" is registered for package %q, expected \"main\"", box.Package)
 	}
 	for _, box := range boxes {
-		validateBox(t, box, sourceFiles)
+		validateBox(t, box, sourceFiles, opts)
 	}
 }
 
-func validateBox(t *testing.T, box *registeredBox, files []sourceFile) {
+func validateBox(t *testing.T, box *registeredBox, files []sourceFile, opts embedGoOptions) {
+	modTime := int(opts.modTime.Unix())
+	if !opts.modTime.IsZero() && box.Time != modTime {
+		t.Errorf("box %v has time %v, expected %v", box.Name, box.Time, modTime)
+	}
 	dirsToBeChecked := make(map[string]struct{})
 	filesToBeChecked := make(map[string]string)
 	for _, file := range files {
@@ -593,9 +638,41 @@ func validateBox(t *testing.T, box *registeredBox, files []sourceFile) {
 		if f.Filename != name {
 			t.Errorf("box %v: filename mismatch: key: %v; Filename: %v", box.Name, name, f.Filename)
 		}
+		if f.Encrypted != (opts.encryptionKey != nil) {
+			t.Errorf("box %v: file %v encrypted is %v", box.Name, name, f.Encrypted)
+		}
+		if f.Encrypted {
+			decrypted, err := encryption.Decrypt(opts.encryptionKey, name, []byte(f.Content))
+			if err != nil {
+				t.Errorf("box %v: file %v can't be decrypted: %v", box.Name, name, err)
+			}
+			f.Content = string(decrypted)
+		}
+		if f.Compressed {
+			if !opts.compress {
+				t.Errorf("box %v: file %v is compressed", box.Name, name)
+			}
+			uncompressed, err := embedded.Gunzip([]byte(f.Content))
+			if err != nil {
+				t.Errorf("box %v: file %v can't be decompressed: %v", box.Name, name, err)
+			}
+			f.Content = string(uncompressed)
+			if f.UncompressedSize != len(f.Content) {
+				t.Errorf("box %v: file %v has UncompressedSize %d, expected %d", box.Name, name, f.UncompressedSize, len(f.Content))
+			}
+		}
+		if f.Encrypted && f.Hash != "" {
+			t.Errorf("box %v: encrypted file %v has a hash", box.Name, name)
+		}
+		if hash := sha256.Sum256([]byte(content)); !f.Encrypted && f.Hash != hex.EncodeToString(hash[:]) {
+			t.Errorf("box %v: file %v has hash %q, expected %x", box.Name, name, f.Hash, hash)
+		}
 		if f.Content != content {
 			t.Errorf("box %v: file %v content does not match: got %v, expected %v", box.Name, name, f.Content, content)
 		}
+		if !opts.modTime.IsZero() && f.ModTime != modTime {
+			t.Errorf("box %v: file %v has time %v, expected %v", box.Name, name, f.ModTime, modTime)
+		}
 		dirPath, _ := path.Split(name)
 		dirPath = strings.TrimSuffix(dirPath, "/")
 		dir, ok := box.Dirs[dirPath]
@@ -623,6 +700,9 @@ func validateBox(t *testing.T, box *registeredBox, files []sourceFile) {
 		if d.Filename != name {
 			t.Errorf("box %v: filename mismatch: key: %v; Filename: %v", box.Name, name, d.Filename)
 		}
+		if !opts.modTime.IsZero() && d.ModTime != modTime {
+			t.Errorf("box %v: dir %v has time %v, expected %v", box.Name, name, d.ModTime, modTime)
+		}
 		if name != "" {
 			dirPath, _ := path.Split(name)
 			dirPath = strings.TrimSuffix(dirPath, "/")
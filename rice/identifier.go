@@ -6,9 +6,13 @@ import (
 	"github.com/GeertJohan/go.incremental"
 )
 
-var identifierCount incremental.Uint64
+// identifiers numbers the variables in the code generated for a box. Every
+// box starts at 1, so its code doesn't depend on the other boxes.
+type identifiers struct {
+	count incremental.Uint64
+}
 
-func nextIdentifier() string {
-	num := identifierCount.Next()
+func (ids *identifiers) next() string {
+	num := ids.count.Next()
 	return strconv.FormatUint(num, 36) // 0123456789abcdefghijklmnopqrstuvwxyz
 }
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFilename is the file at the root of a box with the rules for the
+// files that are not embedded or appended, in gitignore syntax
+const ignoreFilename = ".riceignore"
+
+// ignorePattern is a pattern in gitignore syntax
+type ignorePattern struct {
+	segments []string // split at "/", "**" matches zero or more segments
+	negate   bool     // the pattern starts with "!"
+	dirOnly  bool     // the pattern ends with "/"
+}
+
+// parseIgnorePattern parses a line in gitignore syntax, it returns false for
+// blank lines and comments
+func parseIgnorePattern(line string) (ignorePattern, bool, error) {
+	var p ignorePattern
+	pattern := strings.TrimRight(line, " \t\r")
+	if strings.HasSuffix(pattern, `\`) {
+		// an escaped trailing space
+		pattern += " "
+	}
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return p, false, nil
+	}
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return p, false, fmt.Errorf("invalid pattern %q", line)
+	}
+	// a pattern with a slash at the beginning or in the middle is relative to
+	// the box root, others match at any level
+	anchored := strings.Contains(pattern, "/")
+	p.segments = strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	if !anchored {
+		p.segments = append([]string{"**"}, p.segments...)
+	}
+	for _, segment := range p.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return p, false, fmt.Errorf("invalid pattern %q: %v", line, err)
+		}
+	}
+	return p, true, nil
+}
+
+// match returns whether the pattern matches the slash separated name
+func (p ignorePattern) match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	return matchSegments(p.segments, strings.Split(name, "/"))
+}
+
+// matchSegments matches the segments of a name against those of a pattern
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchLast returns whether the last of the patterns that matches the name
+// isn't negated, and false when none of them matches
+func matchLast(patterns []ignorePattern, name string, isDir bool) bool {
+	matched := false
+	for _, p := range patterns {
+		if p.match(name, isDir) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
+
+// fileRules are the rules for the files of all boxes, given with --exclude,
+// --include and --skip-dotfiles
+type fileRules struct {
+	exclude      []ignorePattern
+	include      []ignorePattern
+	skipDotfiles bool
+}
+
+// fileRulesFromFlags returns the rules given with the flags
+func fileRulesFromFlags() fileRules {
+	rules := fileRules{skipDotfiles: flags.SkipDotfiles}
+	for _, pattern := range flags.Exclude {
+		p, ok, err := parseIgnorePattern(pattern)
+		if err != nil {
+			fmt.Printf("Error parsing --exclude: %s\n", err)
+			os.Exit(1)
+		}
+		if ok {
+			rules.exclude = append(rules.exclude, p)
+		}
+	}
+	for _, pattern := range flags.Include {
+		p, ok, err := parseIgnorePattern(pattern)
+		if err != nil {
+			fmt.Printf("Error parsing --include: %s\n", err)
+			os.Exit(1)
+		}
+		if ok {
+			rules.include = append(rules.include, p)
+		}
+	}
+	return rules
+}
+
+// fileFilter decides which files and dirs of a box are embedded or appended
+type fileFilter struct {
+	rules  fileRules
+	ignore []ignorePattern // from the .riceignore of the box, then --exclude
+}
+
+// filterForBox returns the filter for the box in the given directory, with the
+// rules of its .riceignore
+func (rules fileRules) filterForBox(boxPath string) (*fileFilter, error) {
+	filter := &fileFilter{rules: rules}
+	f, err := os.Open(filepath.Join(boxPath, ignoreFilename))
+	if os.IsNotExist(err) {
+		filter.ignore = rules.exclude
+		return filter, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		p, ok, err := parseIgnorePattern(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", f.Name(), line, err)
+		}
+		if ok {
+			filter.ignore = append(filter.ignore, p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// the flags have the last word
+	filter.ignore = append(filter.ignore, rules.exclude...)
+	return filter, nil
+}
+
+// skip returns whether the file or dir with the given slash separated name in
+// the box is left out. The contents of a dir that is left out are never
+// looked at, so they can't be included again.
+func (filter *fileFilter) skip(name string, isDir bool) bool {
+	if name == "" {
+		// the box itself
+		return false
+	}
+	if name == ignoreFilename || (!isDir && generated(name)) {
+		return true
+	}
+	if filter.rules.skipDotfiles && strings.HasPrefix(path.Base(name), ".") {
+		return true
+	}
+	if matchLast(filter.ignore, name, isDir) {
+		return true
+	}
+	if !isDir && len(filter.rules.include) > 0 && !matchLast(filter.rules.include, name, isDir) {
+		return true
+	}
+	return false
+}
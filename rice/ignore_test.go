@@ -0,0 +1,169 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GeertJohan/go.rice/internal/archive"
+)
+
+func TestFileFilter(t *testing.T) {
+	boxPath := t.TempDir()
+	riceignore := `# editor and OS files
+*.swp
+.DS_Store
+
+node_modules/
+/fixtures
+**/testdata/**
+*.map
+!vendor.js.map
+docs/*.md
+\#literal
+`
+	if err := ioutil.WriteFile(filepath.Join(boxPath, ignoreFilename), []byte(riceignore), 0660); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		rules    fileRules
+		name     string
+		isDir    bool
+		expected bool
+	}{
+		{fileRules{}, "", true, false},
+		{fileRules{}, "index.html", false, false},
+		{fileRules{}, ".riceignore", false, true},
+		{fileRules{}, "sub/rice-box.go", false, true},
+		{fileRules{}, "sub/.index.html.swp", false, true},
+		{fileRules{}, ".DS_Store", false, true},
+		{fileRules{}, "css/.DS_Store", false, true},
+		{fileRules{}, "node_modules", true, true},
+		{fileRules{}, "js/node_modules", true, true},
+		{fileRules{}, "node_modules", false, false},
+		{fileRules{}, "fixtures", true, true},
+		{fileRules{}, "js/fixtures", true, false},
+		{fileRules{}, "js/testdata/a.json", false, true},
+		{fileRules{}, "js/app.js.map", false, true},
+		{fileRules{}, "js/vendor.js.map", false, false},
+		{fileRules{}, "docs/README.md", false, true},
+		{fileRules{}, "docs/api/README.md", false, false},
+		{fileRules{}, "#literal", false, true},
+		{fileRules{}, ".hidden", false, false},
+		{fileRules{skipDotfiles: true}, ".hidden", false, true},
+		{fileRules{skipDotfiles: true}, ".well-known", true, true},
+		{fileRules{exclude: mustParseIgnorePatterns(t, "*.txt")}, "notes.txt", false, true},
+		{fileRules{exclude: mustParseIgnorePatterns(t, "!*.map")}, "js/app.js.map", false, false},
+		{fileRules{include: mustParseIgnorePatterns(t, "*.html", "!draft.html")}, "index.html", false, false},
+		{fileRules{include: mustParseIgnorePatterns(t, "*.html", "!draft.html")}, "draft.html", false, true},
+		{fileRules{include: mustParseIgnorePatterns(t, "*.html", "!draft.html")}, "style.css", false, true},
+		{fileRules{include: mustParseIgnorePatterns(t, "*.html", "!draft.html")}, "css", true, false},
+	}
+	for _, c := range cases {
+		filter, err := c.rules.filterForBox(boxPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if skip := filter.skip(c.name, c.isDir); skip != c.expected {
+			t.Errorf("skip(%q, %v) with %+v = %v, expected %v", c.name, c.isDir, c.rules, skip, c.expected)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(boxPath, ignoreFilename), []byte("ok\n[\n"), 0660); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (fileRules{}).filterForBox(boxPath); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func mustParseIgnorePatterns(t *testing.T, lines ...string) []ignorePattern {
+	var patterns []ignorePattern
+	for _, line := range lines {
+		p, ok, err := parseIgnorePattern(line)
+		if err != nil || !ok {
+			t.Fatalf("parsing %q: %v", line, err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
+
+func TestEmbedAndAppendSkipTheSameFiles(t *testing.T) {
+	sourceFiles := []sourceFile{
+		{
+			"boxes.go",
+			[]byte(`package main
+
+import (
+	"github.com/GeertJohan/go.rice"
+)
+
+func main() {
+	rice.MustFindBox("foo")
+}
+`),
+		},
+		{"foo/.riceignore", []byte("*.map\nnode_modules/\n")},
+		{"foo/index.html", []byte(`index`)},
+		{"foo/.hidden", []byte(`hidden`)},
+		{"foo/js/app.js", []byte(`app`)},
+		{"foo/js/app.js.map", []byte(`map`)},
+		{"foo/js/app.test.js", []byte(`test`)},
+		{"foo/node_modules/dep/index.js", []byte(`dep`)},
+		{"foo/rice-box.go", []byte("package main\n")},
+	}
+	pkg, cleanup, err := setUpTestPkg("foobar", sourceFiles)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := fileRules{
+		exclude:      mustParseIgnorePatterns(t, "*.test.js"),
+		skipDotfiles: true,
+	}
+	expected := []string{"index.html", "js/app.js"}
+
+	var buffer bytes.Buffer
+	if err := writeBoxesGo(pkg, &buffer, embedGoOptions{rules: rules}); err != nil {
+		t.Fatal(err)
+	}
+	boxes, err := parseBoxesGo(buffer.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embeddedFiles := make(map[string]bool)
+	for name := range boxes["foo"].files {
+		embeddedFiles[name] = true
+	}
+	if names := sortedNames(embeddedFiles); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected embedded files %v, got %v", expected, names)
+	}
+
+	zipBuffer := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(zipBuffer)
+	writeBoxes(zipWriter, []*build.Package{pkg}, nil, time.Time{}, rules)
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rd, err := zip.NewReader(bytes.NewReader(zipBuffer.Bytes()), int64(zipBuffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendedFiles := make(map[string]bool)
+	for _, f := range rd.File {
+		if !archive.HasAttr(f.Comment, archive.DirAttr) {
+			appendedFiles[strings.TrimPrefix(f.Name, "main/foo/")] = true
+		}
+	}
+	if names := sortedNames(appendedFiles); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected appended files %v, got %v", expected, names)
+	}
+}
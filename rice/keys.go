@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// readEncryptionKey reads a hex encoded AES key of 16, 24 or 32 bytes, as
+// created by `openssl rand -hex 32`.
+func readEncryptionKey(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: key is not hex encoded: %v", filename, err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("%s: key is %d bytes, expected 16, 24 or 32", filename, len(key))
+}
+
+// readPrivateKey reads an ed25519 private key from a PEM encoded PKCS #8 file,
+// as created by `openssl genpkey -algorithm ed25519`.
+func readPrivateKey(filename string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(filename, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 private key", filename)
+	}
+	return edKey, nil
+}
+
+// readPublicKey reads an ed25519 public key from a PEM encoded PKIX file,
+// as created by `openssl pkey -pubout`.
+func readPublicKey(filename string) (ed25519.PublicKey, error) {
+	der, err := readPEM(filename, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 public key", filename)
+	}
+	return edKey, nil
+}
+
+// readPEM returns the data of the first PEM block of the given type in the file.
+func readPEM(filename, blockType string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New(filename + ": no " + blockType + " found")
+		}
+		if block.Type == blockType {
+			return block.Bytes, nil
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// listedPackage holds the fields of the JSON output of `go list` that the
+// rice tool uses
+type listedPackage struct {
+	Dir        string
+	ImportPath string
+	Name       string
+	GoFiles    []string
+	CgoFiles   []string
+	Imports    []string
+	ImportMap  map[string]string // import paths in the source to the resolved ones, e.g. for vendored packages
+	DepOnly    bool              // only listed as a dependency of the packages that match the patterns
+	Error      *struct {
+		Err string
+	}
+}
+
+// listedPackages holds the packages that loadPackages listed by import path,
+// including their dependencies and the standard library. The type checker of
+// findBoxes imports them from source.
+var listedPackages = make(map[string]*listedPackage)
+
+// loadPackages loads the packages that match the patterns the way `go list`
+// does, from the given directory: module aware, with patterns such as ./...
+// and with the given build tags. The dependencies of the packages are kept in
+// listedPackages. Packages that can't be loaded are skipped with a warning,
+// unless they import go.rice: those may have boxes, so their errors are
+// returned.
+func loadPackages(dir string, patterns []string, tags []string) ([]*build.Package, error) {
+	args := []string{"list", "-e", "-deps", "-json"}
+	if len(tags) > 0 {
+		args = append(args, "-tags", strings.Join(tags, ","))
+	}
+	args = append(args, "--")
+	args = append(args, patterns...)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var pkgs []*build.Package
+	var errs []string
+	var skipped error
+	dec := json.NewDecoder(stdout)
+	for {
+		var listed listedPackage
+		err := dec.Decode(&listed)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("go list: %v", err)
+		}
+		listedPackages[listed.ImportPath] = &listed
+		if listed.DepOnly {
+			continue
+		}
+		if listed.Error != nil {
+			if usesRice(&listed) {
+				errs = append(errs, listed.Error.Err)
+				continue
+			}
+			log.Printf("skipping %s: %s\n", listed.ImportPath, listed.Error.Err)
+			if skipped == nil {
+				skipped = errors.New(listed.Error.Err)
+			}
+			continue
+		}
+		pkgs = append(pkgs, &build.Package{
+			Dir:        listed.Dir,
+			ImportPath: listed.ImportPath,
+			Name:       listed.Name,
+			GoFiles:    listed.GoFiles,
+			CgoFiles:   listed.CgoFiles,
+		})
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	if len(pkgs) == 0 {
+		if skipped != nil {
+			return nil, skipped
+		}
+		return nil, fmt.Errorf("no packages match %s", strings.Join(patterns, " "))
+	}
+	// dependencies are listed before the packages that import them
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].ImportPath < pkgs[j].ImportPath
+	})
+	return pkgs, nil
+}
+
+// usesRice returns whether the package imports go.rice
+func usesRice(listed *listedPackage) bool {
+	for _, importPath := range listed.Imports {
+		if isRiceImportPath(importPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadGeneratePackage loads the package that `go generate` runs the tool for:
+// $GOPACKAGE in the working directory. A directive in an external test
+// package (e.g. $GOPACKAGE foo_test) refers to the package under test.
+func loadGeneratePackage(dir string, tags []string) (*build.Package, error) {
+	name := strings.TrimSuffix(os.Getenv("GOPACKAGE"), "_test")
+	pkgs, err := loadPackages(dir, []string{"."}, tags)
+	if err != nil {
+		return nil, err
+	}
+	pkg := pkgs[0]
+	if pkg.Name != name {
+		return nil, fmt.Errorf("package in %s is %s, not $GOPACKAGE %s", dir, pkg.Name, name)
+	}
+	return pkg, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// setUpTestModule writes the files to a new module named example.com/mono and
+// returns its directory
+func setUpTestModule(t *testing.T, files []sourceFile) string {
+	dir := t.TempDir()
+	files = append(files, sourceFile{"go.mod", []byte("module example.com/mono\n\ngo 1.16\n")})
+	for _, f := range files {
+		fullPath := filepath.Join(dir, f.Name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fullPath, f.Contents, 0660); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadPackages(t *testing.T) {
+	dir := setUpTestModule(t, []sourceFile{
+		{"main.go", []byte("package main\n\nfunc main() {}\n")},
+		{"web/web.go", []byte("package web\n")},
+		{"web/static/app.go", []byte("package static\n")},
+		{"web/static/extra.go", []byte("//go:build extra\n// +build extra\n\npackage static\n")},
+		{"tools/tools.go", []byte("package tools\n")},
+	})
+
+	importPaths := func(patterns []string, tags []string) []string {
+		pkgs, err := loadPackages(dir, patterns, tags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, pkg := range pkgs {
+			paths = append(paths, pkg.ImportPath)
+			if pkg.ImportPath == "example.com/mono/web/static" {
+				if pkg.Name != "static" || pkg.Dir != filepath.Join(dir, "web", "static") {
+					t.Errorf("unexpected package %#v", pkg)
+				}
+				if len(tags) == 0 && len(pkg.GoFiles) != 1 {
+					t.Errorf("expected extra.go to be excluded without tags, got %v", pkg.GoFiles)
+				}
+				if len(tags) > 0 && len(pkg.GoFiles) != 2 {
+					t.Errorf("expected extra.go to be included with tags, got %v", pkg.GoFiles)
+				}
+			}
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	expected := "example.com/mono example.com/mono/tools example.com/mono/web example.com/mono/web/static"
+	if paths := strings.Join(importPaths([]string{"./..."}, nil), " "); paths != expected {
+		t.Errorf("expected %s, got %s", expected, paths)
+	}
+	expected = "example.com/mono/web example.com/mono/web/static"
+	if paths := strings.Join(importPaths([]string{"./web/...", "example.com/mono/web"}, []string{"extra"}), " "); paths != expected {
+		t.Errorf("expected %s, got %s", expected, paths)
+	}
+
+	if _, err := loadPackages(dir, []string{"./missing"}, nil); err == nil {
+		t.Error("expected an error for a missing package")
+	}
+	if _, err := loadPackages(dir, []string{"./missing/..."}, nil); err == nil {
+		t.Error("expected an error for a pattern that matches no packages")
+	}
+}
+
+func TestLoadGeneratePackage(t *testing.T) {
+	dir := setUpTestModule(t, []sourceFile{
+		{"web/web.go", []byte("package web\n")},
+	})
+	setGOPACKAGE := func(name string) {
+		if err := os.Setenv("GOPACKAGE", name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer os.Unsetenv("GOPACKAGE")
+
+	for _, name := range []string{"web", "web_test"} {
+		setGOPACKAGE(name)
+		pkg, err := loadGeneratePackage(filepath.Join(dir, "web"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg.ImportPath != "example.com/mono/web" {
+			t.Errorf("unexpected import path %s", pkg.ImportPath)
+		}
+	}
+
+	setGOPACKAGE("other")
+	if _, err := loadGeneratePackage(filepath.Join(dir, "web"), nil); err == nil || !strings.Contains(err.Error(), "not $GOPACKAGE other") {
+		t.Errorf("expected an error for another package, got %v", err)
+	}
+}
+
+// setOffline makes go list resolve imports from go.mod only, without
+// downloading modules, for the duration of the test
+func setOffline(t *testing.T) {
+	for key, value := range map[string]string{"GOFLAGS": "-mod=readonly", "GOPROXY": "off"} {
+		key := key
+		old, ok := os.LookupEnv(key)
+		if err := os.Setenv(key, value); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func TestLoadPackagesWithErrors(t *testing.T) {
+	setOffline(t)
+	dir := setUpTestModule(t, []sourceFile{
+		{"web/web.go", []byte("package web\n")},
+		{"mixed/a.go", []byte("package a\n")},
+		{"mixed/b.go", []byte("package b\n")},
+		{"assets/assets.go", []byte("package assets\n\nimport _ \"github.com/GeertJohan/go.rice\"\n")},
+		{"assets/other.go", []byte("package other\n")},
+	})
+
+	// a broken package that doesn't use rice is skipped
+	pkgs, err := loadPackages(dir, []string{"./web", "./mixed"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].ImportPath != "example.com/mono/web" {
+		t.Errorf("expected only example.com/mono/web, got %v", pkgs)
+	}
+	if _, err := loadPackages(dir, []string{"./mixed"}, nil); err == nil || !strings.Contains(err.Error(), "found packages a") {
+		t.Errorf("expected the error of the only package, got %v", err)
+	}
+
+	// a broken package that uses rice is an error
+	if _, err := loadPackages(dir, []string{"./..."}, nil); err == nil || !strings.Contains(err.Error(), "found packages assets") || strings.Contains(err.Error(), "found packages a ") {
+		t.Errorf("expected only the error of the package that uses rice, got %v", err)
+	}
+}
@@ -21,25 +21,54 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	// find package for path
+	// verify works on an executable, not on packages
+	if flagsParser.Active.Name == "verify" {
+		operationVerify()
+		return
+	}
+
+	// find the packages to work on
 	var pkgs []*build.Package
-	for _, importPath := range flags.ImportPaths {
-		pkg := pkgForPath(importPath)
-		pkg.AllTags = flags.Tags
-		pkgs = append(pkgs, pkg)
+	if !skipPackages() {
+		pkgs = packagesFromFlags()
 	}
 
 	// switch on the operation to perform
 	switch flagsParser.Active.Name {
 	case "embed", "embed-go":
+		opts := embedGoOptions{
+			compress: flags.EmbedGo.Compress,
+			modTime:  modTimeFromFlags(),
+			rules:    fileRulesFromFlags(),
+		}
+		if flags.EmbedGo.EncryptKey != "" {
+			key, err := readEncryptionKey(flags.EmbedGo.EncryptKey)
+			if err != nil {
+				fmt.Printf("Error reading encryption key: %s\n", err)
+				os.Exit(1)
+			}
+			opts.encryptionKey = key
+		}
+		if flags.EmbedGo.Check {
+			upToDate := true
+			for _, pkg := range pkgs {
+				upToDate = operationCheckEmbedGo(pkg, opts) && upToDate
+			}
+			if !upToDate {
+				os.Exit(1)
+			}
+			break
+		}
 		for _, pkg := range pkgs {
-			operationEmbedGo(pkg)
+			operationEmbedGo(pkg, opts)
 		}
 	case "embed-syso":
 		log.Println("WARNING: embedding .syso is experimental..")
 		log.Fatalln("FATAL: embed-syso is broken and will remain unusable until further notice. Please see https://github.com/GeertJohan/go.rice/issues/162")
 	case "append":
 		operationAppend(pkgs)
+	case "pack":
+		operationPack(pkgs)
 	case "clean":
 		for _, pkg := range pkgs {
 			operationClean(pkg)
@@ -60,8 +89,10 @@ func main() {
 	}
 }
 
-// helper function to get *build.Package for given path
-func pkgForPath(path string) *build.Package {
+// packagesFromFlags loads the packages matching the import paths and patterns
+// given, the package that go generate runs the tool for, or the package in the
+// working directory
+func packagesFromFlags() []*build.Package {
 	// get pwd for relative imports
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -69,14 +100,27 @@ func pkgForPath(path string) *build.Package {
 		os.Exit(1)
 	}
 
-	// read full package information
-	pkg, err := build.Import(path, pwd, 0)
+	var pkgs []*build.Package
+	switch {
+	case len(flags.ImportPaths) > 0:
+		pkgs, err = loadPackages(pwd, flags.ImportPaths, flags.Tags)
+	case os.Getenv("GOPACKAGE") != "":
+		verbosef("run by go generate, using package %s in pwd\n", os.Getenv("GOPACKAGE"))
+		var pkg *build.Package
+		pkg, err = loadGeneratePackage(pwd, flags.Tags)
+		pkgs = []*build.Package{pkg}
+	default:
+		verbosef("using pwd as import path\n")
+		pkgs, err = loadPackages(pwd, []string{"."}, flags.Tags)
+	}
 	if err != nil {
-		fmt.Printf("error reading package: %s\n", err)
+		fmt.Printf("error reading packages: %s\n", err)
 		os.Exit(1)
 	}
-
-	return pkg
+	for _, pkg := range pkgs {
+		verbosef("using import path: %s\n", pkg.ImportPath)
+	}
+	return pkgs
 }
 
 func verbosef(format string, stuff ...interface{}) {
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sourceDateEpoch is the environment variable of the reproducible builds
+// specification, with the time to record in unix seconds
+const sourceDateEpoch = "SOURCE_DATE_EPOCH"
+
+// parseRecordedTime parses a time given in unix seconds or as RFC 3339
+func parseRecordedTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither unix seconds nor RFC 3339", value)
+	}
+	return t.UTC(), nil
+}
+
+// modTimeFromFlags returns the time to record for all boxes, dirs and files
+// instead of their modification times: --mod-time, or $SOURCE_DATE_EPOCH.
+// It returns the zero time when neither is set.
+func modTimeFromFlags() time.Time {
+	value, source := flags.ModTime, "--mod-time"
+	if value == "" {
+		value, source = os.Getenv(sourceDateEpoch), "$"+sourceDateEpoch
+	}
+	if value == "" {
+		return time.Time{}
+	}
+	modTime, err := parseRecordedTime(value)
+	if err != nil {
+		fmt.Printf("Error parsing %s: %s\n", source, err)
+		os.Exit(1)
+	}
+	verbosef("recording %s as modification time\n", modTime.Format(time.RFC3339))
+	return modTime
+}
+
+// recordedTime returns the time to record for a box, dir or file modified at
+// the given time
+func recordedTime(modified, modTime time.Time) time.Time {
+	if modTime.IsZero() {
+		return modified
+	}
+	return modTime
+}
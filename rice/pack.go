@@ -0,0 +1,82 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/GeertJohan/go.rice/internal/archive"
+)
+
+// operationPack writes the boxes of the packages to a bundle, a zip archive
+// that is located at runtime through rice.Config.Bundles, or appended to
+// executables with `rice append --bundle`.
+func operationPack(pkgs []*build.Package) {
+	signKey, encryptionKey := readArchiveKeys(flags.Pack.SignKey, flags.Pack.EncryptKey)
+
+	bundleName, err := filepath.Abs(flags.Pack.Output)
+	if err != nil {
+		fmt.Printf("Error finding absolute path for bundle: %s\n", err)
+		os.Exit(1)
+	}
+	verbosef("Will write bundle: %s\n", bundleName)
+
+	// the bundle is written next to its destination and renamed, so a
+	// running program never sees half of it
+	tmpFile, err := ioutil.TempFile(filepath.Dir(bundleName), "."+filepath.Base(bundleName)+"-")
+	if err != nil {
+		fmt.Printf("Error creating tmp bundle: %s\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+	}()
+
+	zipWriter := zip.NewWriter(tmpFile)
+	// mark the archive as storing boxes by namespace
+	if err := zipWriter.SetComment(archive.Comment); err != nil {
+		fmt.Printf("Error setting zip comment: %s\n", err)
+		os.Exit(1)
+	}
+	writeBoxes(zipWriter, pkgs, encryptionKey, modTimeFromFlags(), fileRulesFromFlags())
+	if err := zipWriter.Close(); err != nil {
+		fmt.Printf("Error closing tmp bundle: %s\n", err)
+		os.Exit(1)
+	}
+
+	if signKey != nil {
+		// the signature is added to the comment at the end of the archive
+		zipData, err := ioutil.ReadFile(tmpFile.Name())
+		if err != nil {
+			fmt.Printf("Error reading tmp bundle: %s\n", err)
+			os.Exit(1)
+		}
+		zipData, err = archive.Sign(zipData, signKey)
+		if err != nil {
+			fmt.Printf("Error signing bundle: %s\n", err)
+			os.Exit(1)
+		}
+		if _, err := tmpFile.WriteAt(zipData, 0); err != nil {
+			fmt.Printf("Error writing tmp bundle: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		fmt.Printf("Error closing tmp bundle: %s\n", err)
+		os.Exit(1)
+	}
+	// ioutil.TempFile creates files that only the owner can read
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		fmt.Printf("Error setting bundle permissions: %s\n", err)
+		os.Exit(1)
+	}
+	if err := os.Rename(tmpFile.Name(), bundleName); err != nil {
+		fmt.Printf("Error writing bundle: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -50,7 +50,7 @@ func init() {
 import (
 	"time"
 
-	"github.com/GeertJohan/go.rice/embedded"
+	"{{.RicePath}}/embedded"
 )
 
 {{range .Boxes}}
@@ -61,7 +61,11 @@ func init() {
 		Filename:    {{.FileName | tagescape | printf "%q"}},
 		FileModTime: time.Unix({{.ModTime}}, 0),
 
-		Content:     string({{.Path | injectfile | printf "%q"}}),
+		Content:     string({{.ContentKey | injectfile | printf "%q"}}),
+		{{if .Encrypted}}Encrypted: true,{{end}}
+		{{if .Compressed}}Compressed: true,
+		UncompressedSize: {{.UncompressedSize}},{{end}}
+		{{if .Hash}}Hash: {{.Hash | printf "%q"}},{{end}}
 	}
 	{{end}}
 
@@ -86,6 +90,7 @@ func init() {
 	// register embeddedBox
 	embedded.RegisterEmbeddedBox(` + "`" + `{{.BoxName}}` + "`" + `, &embedded.EmbeddedBox{
 		Name: ` + "`" + `{{.BoxName}}` + "`" + `,
+		Package: {{$.Namespace | printf "%q"}},
 		Time: time.Unix({{.UnixNow}}, 0),
 		Dirs: map[string]*embedded.EmbeddedDir{
 			{{range .Dirs}}{{.FileName | tagescape | printf "%q"}}: {{.Identifier}},
@@ -105,7 +110,8 @@ func init() {
 }
 
 // embeddedBoxFasttemplate will inject file contents and unescape {% and %}.
-func embeddedBoxFasttemplate(w io.Writer, src string) error {
+// The content to inject for the key of a file is read from openContent.
+func embeddedBoxFasttemplate(w io.Writer, src string, openContent func(key string) (io.ReadCloser, error)) error {
 	ft, err := fasttemplate.NewTemplate(src, "{%", "%}")
 	if err != nil {
 		return fmt.Errorf("error compiling fasttemplate: %s\n", err)
@@ -127,7 +133,7 @@ func embeddedBoxFasttemplate(w io.Writer, src string) error {
 		if err != nil {
 			return 0, fmt.Errorf("error unquoting filename %v: %v\n", tag, err)
 		}
-		f, err := os.Open(tagUnescaper.Replace(fileName))
+		f, err := openContent(tagUnescaper.Replace(fileName))
 		if err != nil {
 			return 0, fmt.Errorf("error opening file %v: %v\n", fileName, err)
 		}
@@ -149,8 +155,10 @@ func embeddedBoxFasttemplate(w io.Writer, src string) error {
 }
 
 type embedFileDataType struct {
-	Package string
-	Boxes   []*boxDataType
+	Package   string
+	Namespace string
+	RicePath  string // import path of go.rice, as imported by the package
+	Boxes     []*boxDataType
 }
 
 type boxDataType struct {
@@ -161,10 +169,14 @@ type boxDataType struct {
 }
 
 type fileDataType struct {
-	Identifier string
-	FileName   string
-	Path       string
-	ModTime    int64
+	Identifier       string
+	FileName         string
+	ContentKey       string // key of the content to inject
+	ModTime          int64
+	Encrypted        bool
+	Compressed       bool
+	UncompressedSize int64
+	Hash             string
 }
 
 type dirDataType struct {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"go/build"
 	"math/rand"
 	"path/filepath"
 	"strings"
@@ -13,6 +14,16 @@ func generated(filename string) bool {
 		strings.HasSuffix(filename, "."+boxFilename)
 }
 
+// boxNamespace returns the namespace under which the boxes used by pkg are
+// registered. It matches the import path that the go.rice package derives
+// from the stack at runtime, which is "main" for commands.
+func boxNamespace(pkg *build.Package) string {
+	if pkg.Name == "main" {
+		return "main"
+	}
+	return pkg.ImportPath
+}
+
 // randomString generates a pseudo-random alpha-numeric string with given length.
 func randomString(length int) string {
 	rand.Seed(time.Now().UnixNano())
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GeertJohan/go.rice/internal/archive"
+)
+
+func operationVerify() {
+	key, err := readPublicKey(flags.Verify.PublicKey)
+	if err != nil {
+		fmt.Printf("Error reading public key: %s\n", err)
+		os.Exit(1)
+	}
+
+	binfileName, err := filepath.Abs(flags.Verify.Executable)
+	if err != nil {
+		fmt.Printf("Error finding absolute path for executable to verify: %s\n", err)
+		os.Exit(1)
+	}
+	binfile, err := os.Open(binfileName)
+	if err != nil {
+		fmt.Printf("Error: unable to open executable file: %s\n", err)
+		os.Exit(1)
+	}
+	defer binfile.Close()
+	binfileInfo, err := binfile.Stat()
+	if err != nil {
+		fmt.Printf("Error: unable to stat executable file: %s\n", err)
+		os.Exit(1)
+	}
+
+	// rice append writes the archive with offsets relative to the start of the executable
+	rd, err := zip.NewReader(binfile, binfileInfo.Size())
+	if err != nil {
+		fmt.Printf("Error: no archive appended by rice found in %s: %s\n", binfileName, err)
+		os.Exit(1)
+	}
+	err = archive.Verify(binfile, binfileInfo.Size(), rd.Comment, key)
+	if err != nil {
+		fmt.Printf("Error verifying %s: %s\n", binfileName, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: signature is valid\n", binfileName)
+}
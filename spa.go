@@ -0,0 +1,139 @@
+package rice
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SPAHandler serves a single-page application from a box. Requests for paths
+// that match no file in the box are served the fallback document (usually
+// index.html), so the application can handle its own routes.
+//
+//	e.g.: http.Handle("/", rice.MustFindBox("app").HTTPBox().SPAHandler())
+//
+// Files are served like HTTPBox.ServeHTTP does, including precompressed
+// variants.
+type SPAHandler struct {
+	Box *HTTPBox
+
+	// FallbackFile is the name of the file in the box that is served for
+	// unknown paths. Defaults to "index.html".
+	FallbackFile string
+
+	// NoFallbackPrefixes are request path prefixes that never fall back,
+	// e.g. "/static/". A missing file under such a prefix is a 404.
+	NoFallbackPrefixes []string
+
+	// FallbackCacheControl is the Cache-Control header sent with the fallback
+	// document, also when it is requested directly. It should make clients
+	// revalidate, so they pick up new versions of the application.
+	FallbackCacheControl string
+
+	// AssetCacheControl is the Cache-Control header sent with hashed assets,
+	// files with a content hash in their name that never change, and with
+	// files requested by their fingerprinted name (see HTTPBox.Fingerprinting).
+	AssetCacheControl string
+
+	// IsHashedAsset reports whether the file with the given name is a hashed
+	// asset. When nil, names with a hexadecimal hash of at least 8 characters
+	// between dots or dashes are hashed assets, e.g. "main.3f2a9c1b.js" or
+	// "chunk-3f2a9c1b.css".
+	IsHashedAsset func(name string) bool
+}
+
+// SPAHandler creates a new SPAHandler for the box, which falls back to
+// index.html and uses the default cache headers.
+func (hb *HTTPBox) SPAHandler() *SPAHandler {
+	return &SPAHandler{
+		Box:                  hb,
+		FallbackFile:         "index.html",
+		FallbackCacheControl: DefaultFallbackCacheControl,
+		AssetCacheControl:    DefaultAssetCacheControl,
+	}
+}
+
+// hashedAssetName matches file names with a content hash, e.g. main.3f2a9c1b.js
+var hashedAssetName = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}[.-]`)
+
+func isHashedAsset(name string) bool {
+	return hashedAssetName.MatchString(path.Base(name))
+}
+
+// ServeHTTP serves the file that matches the request path, or the fallback
+// document when there is none. Directories are never listed.
+func (h *SPAHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if name, fingerprinted, ok := h.Box.requestedFile(r); ok {
+		h.setCacheControl(w, name, fingerprinted)
+		h.Box.serveFile(w, r, name)
+		return
+	}
+	switch {
+	case !h.missing(r):
+		// redirects, invalid paths and other methods
+		h.Box.ServeHTTP(w, r)
+	case !h.fallsBack(r):
+		serveError(w, os.ErrNotExist)
+	default:
+		name := h.fallbackFile()
+		h.setCacheControl(w, name, false)
+		h.Box.serveFile(w, r, name)
+	}
+}
+
+func (h *SPAHandler) fallbackFile() string {
+	if h.FallbackFile == "" {
+		return "index.html"
+	}
+	return strings.TrimPrefix(h.FallbackFile, "/")
+}
+
+// missing reports whether nothing can be served for the request, for which
+// no file was found: the path doesn't exist, or is a directory without
+// index.html, which isn't listed.
+func (h *SPAHandler) missing(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	name, err := cleanName("open", r.URL.Path)
+	if err != nil {
+		return false
+	}
+	info, err := h.Box.stat(name)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	// http.FileServer redirects directories without a trailing slash
+	return info.IsDir() && strings.HasSuffix(r.URL.Path, "/")
+}
+
+// fallsBack reports whether the request path may be served the fallback document
+func (h *SPAHandler) fallsBack(r *http.Request) bool {
+	for _, prefix := range h.NoFallbackPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// setCacheControl sets the Cache-Control header for the file with the given
+// name, which may have been requested by its fingerprinted name
+func (h *SPAHandler) setCacheControl(w http.ResponseWriter, name string, fingerprinted bool) {
+	hashed := h.IsHashedAsset
+	if hashed == nil {
+		hashed = isHashedAsset
+	}
+	switch {
+	case name == h.fallbackFile() && !fingerprinted:
+		if h.FallbackCacheControl != "" {
+			w.Header().Set("Cache-Control", h.FallbackCacheControl)
+		}
+	case fingerprinted || hashed(name):
+		if h.AssetCacheControl != "" {
+			w.Header().Set("Cache-Control", h.AssetCacheControl)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package rice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSPAHandler(t *testing.T) {
+	files := map[string]string{
+		"index.html":                  "<html>app</html>",
+		"app.html":                    "<html>other app</html>",
+		"favicon.ico":                 "icon",
+		"static/js/main.3f2a9c1b.js":  "main",
+		"static/css/app-0123abcd.css": "css",
+		"static/media/logo.svg":       "<svg></svg>",
+		"docs/readme.txt":             "readme",
+	}
+
+	cases := []struct {
+		path         string
+		status       int
+		body         string
+		cacheControl string
+	}{
+		{"/", http.StatusOK, files["index.html"], DefaultFallbackCacheControl},
+		{"/users/42", http.StatusOK, files["index.html"], DefaultFallbackCacheControl},
+		{"/users/42/", http.StatusOK, files["index.html"], DefaultFallbackCacheControl},
+		{"/docs/", http.StatusOK, files["index.html"], DefaultFallbackCacheControl},
+		{"/favicon.ico", http.StatusOK, "icon", ""},
+		{"/docs/readme.txt", http.StatusOK, "readme", ""},
+		{"/static/js/main.3f2a9c1b.js", http.StatusOK, "main", DefaultAssetCacheControl},
+		{"/static/css/app-0123abcd.css", http.StatusOK, "css", DefaultAssetCacheControl},
+		{"/static/media/logo.svg", http.StatusOK, "<svg></svg>", ""},
+		{"/static/js/missing.js", http.StatusNotFound, "", ""},
+		{"/static/", http.StatusNotFound, "", ""},
+		{"/index.html", http.StatusMovedPermanently, "", ""},
+		{"/docs", http.StatusMovedPermanently, "", ""},
+	}
+	for method, box := range newTestBoxesWithFiles(t, files) {
+		handler := box.HTTPBox().SPAHandler()
+		handler.NoFallbackPrefixes = []string{"/static/"}
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			for _, c := range cases {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest("GET", c.path, nil))
+				resp := rec.Result()
+				if resp.StatusCode != c.status {
+					t.Errorf("%s: status %d, expected %d", c.path, resp.StatusCode, c.status)
+					continue
+				}
+				if c.status != http.StatusOK {
+					continue
+				}
+				if body := rec.Body.String(); body != c.body {
+					t.Errorf("%s: body %q, expected %q", c.path, body, c.body)
+				}
+				if cc := resp.Header.Get("Cache-Control"); cc != c.cacheControl {
+					t.Errorf("%s: Cache-Control %q, expected %q", c.path, cc, c.cacheControl)
+				}
+			}
+
+			// a POST never falls back
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("POST", "/users/42", nil))
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("POST: status %d, expected %d", rec.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestSPAHandlerOptions(t *testing.T) {
+	box := newEmbeddedTestBoxWithFiles(map[string]string{
+		"app.html":        "<html>app</html>",
+		"assets/main.js":  "main",
+		"assets/other.js": "other",
+	})
+	handler := &SPAHandler{
+		Box:                  box.HTTPBox(),
+		FallbackFile:         "app.html",
+		FallbackCacheControl: "no-store",
+		AssetCacheControl:    "max-age=60",
+		IsHashedAsset: func(name string) bool {
+			return name == "assets/main.js"
+		},
+	}
+
+	cases := []struct {
+		path         string
+		body         string
+		cacheControl string
+	}{
+		{"/settings", "<html>app</html>", "no-store"},
+		{"/app.html", "<html>app</html>", "no-store"},
+		{"/assets/main.js", "main", "max-age=60"},
+		{"/assets/other.js", "other", ""},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", c.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status %d", c.path, rec.Code)
+			continue
+		}
+		if body := rec.Body.String(); body != c.body {
+			t.Errorf("%s: body %q, expected %q", c.path, body, c.body)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != c.cacheControl {
+			t.Errorf("%s: Cache-Control %q, expected %q", c.path, cc, c.cacheControl)
+		}
+		if ctype := rec.Header().Get("Content-Type"); c.body[0] == '<' && ctype != "text/html; charset=utf-8" {
+			t.Errorf("%s: Content-Type %q", c.path, ctype)
+		}
+	}
+}
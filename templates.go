@@ -0,0 +1,272 @@
+package rice
+
+import (
+	"errors"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// TemplateConfig allows customizing how templates are parsed from a box.
+type TemplateConfig struct {
+	// Funcs are added to the templates before they are parsed, see
+	// template.Funcs. AssetFuncs provides functions for fingerprinted files.
+	Funcs map[string]interface{}
+
+	// LeftDelim and RightDelim are the action delimiters, "{{" and "}}" when
+	// empty.
+	LeftDelim  string
+	RightDelim string
+
+	// Reload makes templates from a box on disk parse again when a template
+	// file was changed, added or removed, so edits show up without a
+	// restart. The files are checked every time the templates are used.
+	// Embedded and appended boxes never change, their templates are parsed
+	// once.
+	Reload bool
+}
+
+// ParseHTMLTemplates parses the files in the box that match any of the
+// patterns as html templates, see TemplateConfig.ParseHTMLTemplates.
+func (b *Box) ParseHTMLTemplates(patterns ...string) (*HTMLTemplates, error) {
+	return (&TemplateConfig{}).ParseHTMLTemplates(b, patterns...)
+}
+
+// ParseTextTemplates parses the files in the box that match any of the
+// patterns as text templates, see TemplateConfig.ParseTextTemplates.
+func (b *Box) ParseTextTemplates(patterns ...string) (*TextTemplates, error) {
+	return (&TemplateConfig{}).ParseTextTemplates(b, patterns...)
+}
+
+// ParseHTMLTemplates parses the files in the box that match any of the
+// patterns (see Box.Glob) as html templates. Every template is named after
+// the path of its file in the box, e.g. "pages/index.html". It is an error
+// when a pattern matches no files.
+func (c *TemplateConfig) ParseHTMLTemplates(box *Box, patterns ...string) (*HTMLTemplates, error) {
+	set, err := c.newTemplateSet(box, patterns, func(files []templateFile) (interface{}, error) {
+		var t *htmltemplate.Template
+		for _, f := range files {
+			var tmpl *htmltemplate.Template
+			if t == nil {
+				t = htmltemplate.New(f.name).Funcs(c.Funcs).Delims(c.LeftDelim, c.RightDelim)
+				tmpl = t
+			} else {
+				tmpl = t.New(f.name)
+			}
+			if _, err := tmpl.Parse(f.content); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &HTMLTemplates{set}, nil
+}
+
+// ParseTextTemplates parses the files in the box that match any of the
+// patterns (see Box.Glob) as text templates. Every template is named after
+// the path of its file in the box, e.g. "mail/welcome.txt". It is an error
+// when a pattern matches no files.
+func (c *TemplateConfig) ParseTextTemplates(box *Box, patterns ...string) (*TextTemplates, error) {
+	set, err := c.newTemplateSet(box, patterns, func(files []templateFile) (interface{}, error) {
+		var t *texttemplate.Template
+		for _, f := range files {
+			var tmpl *texttemplate.Template
+			if t == nil {
+				t = texttemplate.New(f.name).Funcs(c.Funcs).Delims(c.LeftDelim, c.RightDelim)
+				tmpl = t
+			} else {
+				tmpl = t.New(f.name)
+			}
+			if _, err := tmpl.Parse(f.content); err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TextTemplates{set}, nil
+}
+
+// HTMLTemplates holds html templates parsed from a box
+type HTMLTemplates struct {
+	set *templateSet
+}
+
+// Template returns the templates. When the templates are reloaded (see
+// TemplateConfig.Reload), it returns the error of parsing the changed files.
+func (t *HTMLTemplates) Template() (*htmltemplate.Template, error) {
+	tmpl, err := t.set.current()
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.(*htmltemplate.Template), nil
+}
+
+// ExecuteTemplate applies the template with the given name to data, and
+// writes the output to w.
+func (t *HTMLTemplates) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	tmpl, err := t.Template()
+	if err != nil {
+		return err
+	}
+	return tmpl.ExecuteTemplate(w, name, data)
+}
+
+// TextTemplates holds text templates parsed from a box
+type TextTemplates struct {
+	set *templateSet
+}
+
+// Template returns the templates. When the templates are reloaded (see
+// TemplateConfig.Reload), it returns the error of parsing the changed files.
+func (t *TextTemplates) Template() (*texttemplate.Template, error) {
+	tmpl, err := t.set.current()
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.(*texttemplate.Template), nil
+}
+
+// ExecuteTemplate applies the template with the given name to data, and
+// writes the output to w.
+func (t *TextTemplates) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	tmpl, err := t.Template()
+	if err != nil {
+		return err
+	}
+	return tmpl.ExecuteTemplate(w, name, data)
+}
+
+// templateFile is a template file read from a box
+type templateFile struct {
+	name    string
+	content string
+}
+
+// templateFileState is what is known about a template file when it was parsed
+type templateFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// templateSet holds the templates parsed from the files in a box that match
+// the patterns, and parses them again when the files change.
+type templateSet struct {
+	box      *Box
+	patterns []string
+	reload   bool
+	parse    func(files []templateFile) (interface{}, error)
+
+	mu    sync.Mutex
+	tmpl  interface{}                  // the parsed templates
+	files map[string]templateFileState // the files the templates were parsed from
+}
+
+func (c *TemplateConfig) newTemplateSet(box *Box, patterns []string, parse func([]templateFile) (interface{}, error)) (*templateSet, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no template patterns given")
+	}
+	set := &templateSet{
+		box:      box,
+		patterns: patterns,
+		// embedded and appended boxes never change
+		reload: c.Reload && box.isLive(),
+		parse:  parse,
+	}
+	files, err := set.match()
+	if err != nil {
+		return nil, err
+	}
+	if err := set.load(files); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// match returns the files in the box that match any of the patterns
+func (s *templateSet) match() (map[string]templateFileState, error) {
+	files := make(map[string]templateFileState)
+	for _, pattern := range s.patterns {
+		names, err := s.box.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matched := false
+		for _, name := range names {
+			info, err := s.box.stat(name)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			matched = true
+			files[name] = templateFileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		if !matched {
+			return nil, &os.PathError{Op: "match", Path: pattern, Err: os.ErrNotExist}
+		}
+	}
+	return files, nil
+}
+
+// load reads and parses the given files
+func (s *templateSet) load(files map[string]templateFileState) error {
+	list := make([]templateFile, 0, len(files))
+	for name := range files {
+		content, err := s.box.String(name)
+		if err != nil {
+			return err
+		}
+		list = append(list, templateFile{name: name, content: content})
+	}
+	// parse in a stable order, the first file names the returned template
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	tmpl, err := s.parse(list)
+	if err != nil {
+		return err
+	}
+	s.tmpl = tmpl
+	s.files = files
+	return nil
+}
+
+// current returns the templates, parsed again when reloading is enabled and
+// the files changed.
+func (s *templateSet) current() (interface{}, error) {
+	if !s.reload {
+		return s.tmpl, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.match()
+	if err != nil {
+		return nil, err
+	}
+	if !templateFilesChanged(s.files, files) {
+		return s.tmpl, nil
+	}
+	if err := s.load(files); err != nil {
+		return nil, err
+	}
+	return s.tmpl, nil
+}
+
+// templateFilesChanged returns whether any file was added, removed or changed
+func templateFilesChanged(old, new map[string]templateFileState) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	for name, state := range new {
+		if oldState, ok := old[name]; !ok || !oldState.modTime.Equal(state.modTime) || oldState.size != state.size {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package rice
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTemplateFiles = map[string]string{
+	"layout.html":      `<h1>{{template "pages/title.html" .}}</h1>`,
+	"pages/title.html": `{{.}}`,
+	"mail/welcome.txt": `Hello [[upper .]], {{not an action}}`,
+	"static/app.js":    `{{ this is not a template`,
+}
+
+func TestParseTemplates(t *testing.T) {
+	for method, box := range newTestBoxesWithFiles(t, testTemplateFiles) {
+		t.Run(locateMethodName(method), func(t *testing.T) {
+			html, err := box.ParseHTMLTemplates("*.html", "pages/*.html")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := html.ExecuteTemplate(&buf, "layout.html", "<b>rice</b>"); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != "<h1>&lt;b&gt;rice&lt;/b&gt;</h1>" {
+				t.Errorf("unexpected output %q", buf.String())
+			}
+			tmpl, err := html.Template()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tmpl.Name() != "layout.html" || tmpl.Lookup("pages/title.html") == nil {
+				t.Errorf("unexpected templates %s", tmpl.DefinedTemplates())
+			}
+
+			cfg := &TemplateConfig{
+				Funcs:      map[string]interface{}{"upper": strings.ToUpper},
+				LeftDelim:  "[[",
+				RightDelim: "]]",
+			}
+			text, err := cfg.ParseTextTemplates(box, "mail/*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf.Reset()
+			if err := text.ExecuteTemplate(&buf, "mail/welcome.txt", "<rice>"); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != "Hello <RICE>, {{not an action}}" {
+				t.Errorf("unexpected output %q", buf.String())
+			}
+
+			all, err := box.ParseTextTemplates("**/*.html")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tmpl, _ := all.Template(); tmpl.Lookup("layout.html") == nil || tmpl.Lookup("pages/title.html") == nil {
+				t.Errorf("unexpected templates %s", tmpl.DefinedTemplates())
+			}
+
+			if _, err := box.ParseHTMLTemplates("*.html", "*.missing"); !os.IsNotExist(err) {
+				t.Errorf("pattern without matches: expected a not exist error, got %v", err)
+			}
+			if _, err := box.ParseHTMLTemplates("[*.html"); err == nil {
+				t.Error("expected an error for an invalid pattern")
+			}
+			if _, err := box.ParseTextTemplates("static/*"); err == nil {
+				t.Error("expected an error for an invalid template")
+			}
+			if _, err := box.ParseTextTemplates(); err == nil {
+				t.Error("expected an error without patterns")
+			}
+		})
+	}
+}
+
+func TestParseTemplatesReload(t *testing.T) {
+	box := newFSTestBoxWithFiles(t, testTemplateFiles)
+	writeFile := func(name, content string, modTime time.Time) {
+		fullPath := filepath.Join(box.absolutePath, filepath.FromSlash(name))
+		if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(fullPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	execute := func(templates *HTMLTemplates, name string) string {
+		var buf bytes.Buffer
+		if err := templates.ExecuteTemplate(&buf, name, "rice"); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	reloading, err := (&TemplateConfig{Reload: true}).ParseHTMLTemplates(box, "*.html", "pages/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	static, err := box.ParseHTMLTemplates("*.html", "pages/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	writeFile("pages/title.html", "title: {{.}}", later)
+	writeFile("pages/new.html", "new page", later)
+	if out := execute(reloading, "layout.html"); out != "<h1>title: rice</h1>" {
+		t.Errorf("changed template wasn't reloaded: %q", out)
+	}
+	if out := execute(reloading, "pages/new.html"); out != "new page" {
+		t.Errorf("added template wasn't loaded: %q", out)
+	}
+	if out := execute(static, "layout.html"); out != "<h1>rice</h1>" {
+		t.Errorf("template without reload changed: %q", out)
+	}
+
+	// a broken template is reported, until it is fixed
+	writeFile("pages/title.html", "{{.", later.Add(time.Hour))
+	if _, err := reloading.Template(); err == nil {
+		t.Error("expected the parse error of the changed template")
+	}
+	writeFile("pages/title.html", "fixed {{.}}", later.Add(2*time.Hour))
+	if out := execute(reloading, "layout.html"); out != "<h1>fixed rice</h1>" {
+		t.Errorf("fixed template wasn't reloaded: %q", out)
+	}
+
+	// templates from embedded boxes are never reloaded
+	embedded := newEmbeddedTestBoxWithFiles(testTemplateFiles)
+	templates, err := (&TemplateConfig{Reload: true}).ParseHTMLTemplates(embedded, "*.html", "pages/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if templates.set.reload {
+		t.Error("templates of an embedded box are reloaded")
+	}
+}
@@ -4,166 +4,183 @@ import (
 	"errors"
 	"io"
 	"os"
-	"path/filepath"
-	"sort"
-
-	"github.com/GeertJohan/go.rice/embedded"
+	"syscall"
 )
 
-//++ TODO: IDEA: merge virtualFile and virtualDir, this decreases work done by rice.File
-
 // virtualFile is a 'stateful' virtual file.
-// virtualFile wraps an *EmbeddedFile for a call to Box.Open() and virtualizes 'read cursor' (offset) and 'closing'.
+// virtualFile wraps the content of an embedded or appended file for a call to Box.Open() and virtualizes 'read cursor' (offset) and 'closing'.
 // virtualFile is only internally visible and should be exposed through rice.File
 type virtualFile struct {
-	*embedded.EmbeddedFile       // the actual embedded file, embedded to obtain methods
-	offset                 int64 // read position on the virtual file
-	closed                 bool  // closed when true
+	name    string      // path of the file within the box, as used in errors
+	info    os.FileInfo // returned by stat
+	content io.ReaderAt // the actual file content
+	size    int64       // length of content
+	offset  int64       // read position on the virtual file
+	closed  bool        // closed when true
 }
 
-// create a new virtualFile for given EmbeddedFile
-func newVirtualFile(ef *embedded.EmbeddedFile) *virtualFile {
-	vf := &virtualFile{
-		EmbeddedFile: ef,
-		offset:       0,
-		closed:       false,
-	}
-	return vf
+// create a new virtualFile for given content
+func newVirtualFile(name string, info os.FileInfo, content io.ReaderAt, size int64) *virtualFile {
+	return &virtualFile{
+		name:    name,
+		info:    info,
+		content: content,
+		size:    size,
+	}
 }
 
-//++ TODO check for nil pointers in all these methods. When so: return os.PathError with Err: os.ErrInvalid
+// pathError creates an *os.PathError for the given operation on this file
+func (vf *virtualFile) pathError(op string, err error) error {
+	return &os.PathError{
+		Op:   op,
+		Path: vf.name,
+		Err:  err,
+	}
+}
 
 func (vf *virtualFile) close() error {
 	if vf.closed {
-		return &os.PathError{
-			Op:   "close",
-			Path: vf.EmbeddedFile.Filename,
-			Err:  errors.New("already closed"),
-		}
+		return vf.pathError("close", os.ErrClosed)
 	}
-	vf.EmbeddedFile = nil
 	vf.closed = true
+	// release resources held by the content, e.g. a decompressor
+	if closer, ok := vf.content.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return vf.pathError("close", err)
+		}
+	}
 	return nil
 }
 
 func (vf *virtualFile) stat() (os.FileInfo, error) {
 	if vf.closed {
-		return nil, &os.PathError{
-			Op:   "stat",
-			Path: vf.EmbeddedFile.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return nil, vf.pathError("stat", os.ErrClosed)
 	}
-	return (*embeddedFileInfo)(vf.EmbeddedFile), nil
+	return vf.info, nil
 }
 
 func (vf *virtualFile) readdir(count int) ([]os.FileInfo, error) {
 	if vf.closed {
-		return nil, &os.PathError{
-			Op:   "readdir",
-			Path: vf.EmbeddedFile.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return nil, vf.pathError("readdir", os.ErrClosed)
 	}
-	return nil, os.ErrInvalid
+	return nil, vf.pathError("readdirent", syscall.ENOTDIR)
 }
 
 func (vf *virtualFile) readdirnames(count int) ([]string, error) {
 	if vf.closed {
-		return nil, &os.PathError{
-			Op:   "readdirnames",
-			Path: vf.EmbeddedFile.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return nil, vf.pathError("readdir", os.ErrClosed)
 	}
-	return nil, os.ErrInvalid
+	return nil, vf.pathError("readdirent", syscall.ENOTDIR)
 }
 
 func (vf *virtualFile) read(bts []byte) (int, error) {
 	if vf.closed {
-		return 0, &os.PathError{
-			Op:   "read",
-			Path: vf.EmbeddedFile.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return 0, vf.pathError("read", os.ErrClosed)
 	}
-
-	end := vf.offset + int64(len(bts))
-
-	if end >= int64(len(vf.Content)) {
-		// end of file, so return what we have + EOF
-		n := copy(bts, vf.Content[vf.offset:])
-		vf.offset = 0
-		return n, io.EOF
+	if len(bts) == 0 {
+		return 0, nil
+	}
+	if vf.offset >= vf.size {
+		return 0, io.EOF
 	}
 
-	n := copy(bts, vf.Content[vf.offset:end])
+	n, err := vf.content.ReadAt(bts, vf.offset)
 	vf.offset += int64(n)
-	return n, nil
+	if err == io.EOF && vf.offset < vf.size {
+		// the content is shorter than it claims to be, don't silently truncate
+		err = io.ErrUnexpectedEOF
+	}
+	if err == io.EOF && n > 0 {
+		// like os.File, only report EOF when no data was read
+		err = nil
+	}
+	if err != nil && err != io.EOF {
+		return n, vf.pathError("read", err)
+	}
+	return n, err
+}
 
+func (vf *virtualFile) readAt(bts []byte, offset int64) (int, error) {
+	if vf.closed {
+		return 0, vf.pathError("read", os.ErrClosed)
+	}
+	if offset < 0 {
+		return 0, vf.pathError("readat", errors.New("negative offset"))
+	}
+	if offset >= vf.size {
+		if len(bts) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	n, err := vf.content.ReadAt(bts, offset)
+	if err == io.EOF && offset+int64(n) < vf.size {
+		// the content is shorter than it claims to be, don't silently truncate
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		return n, vf.pathError("read", err)
+	}
+	return n, err
 }
 
 func (vf *virtualFile) seek(offset int64, whence int) (int64, error) {
 	if vf.closed {
-		return 0, &os.PathError{
-			Op:   "seek",
-			Path: vf.EmbeddedFile.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return 0, vf.pathError("seek", os.ErrClosed)
 	}
-	var e error
 
-	//++ TODO: check if this is correct implementation for seek
+	var abs int64
 	switch whence {
-	case os.SEEK_SET:
-		//++ check if new offset isn't out of bounds, set e when it is, then break out of switch
-		vf.offset = offset
-	case os.SEEK_CUR:
-		//++ check if new offset isn't out of bounds, set e when it is, then break out of switch
-		vf.offset += offset
-	case os.SEEK_END:
-		//++ check if new offset isn't out of bounds, set e when it is, then break out of switch
-		vf.offset = int64(len(vf.EmbeddedFile.Content)) - offset
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = vf.offset + offset
+	case io.SeekEnd:
+		abs = vf.size + offset
+	default:
+		return 0, vf.pathError("seek", syscall.EINVAL)
 	}
-
-	if e != nil {
-		return 0, &os.PathError{
-			Op:   "seek",
-			Path: vf.Filename,
-			Err:  e,
-		}
+	if abs < 0 {
+		return 0, vf.pathError("seek", syscall.EINVAL)
 	}
 
+	vf.offset = abs
 	return vf.offset, nil
 }
 
 // virtualDir is a 'stateful' virtual directory.
-// virtualDir wraps an *EmbeddedDir for a call to Box.Open() and virtualizes 'closing'.
+// virtualDir wraps the listing of an embedded or appended directory for a call to Box.Open() and virtualizes 'readdir cursor' (offset) and 'closing'.
 // virtualDir is only internally visible and should be exposed through rice.File
 type virtualDir struct {
-	*embedded.EmbeddedDir
-	offset int // readdir position on the directory
-	closed bool
+	name    string        // path of the directory within the box, as used in errors
+	info    os.FileInfo   // returned by stat
+	entries []os.FileInfo // directory listing, sorted by name
+	offset  int           // readdir position on the directory
+	closed  bool          // closed when true
 }
 
-// create a new virtualDir for given EmbeddedDir
-func newVirtualDir(ed *embedded.EmbeddedDir) *virtualDir {
-	vd := &virtualDir{
-		EmbeddedDir: ed,
-		offset:      0,
-		closed:      false,
-	}
-	return vd
+// create a new virtualDir for given directory listing, the listing must be sorted by name
+func newVirtualDir(name string, info os.FileInfo, entries []os.FileInfo) *virtualDir {
+	return &virtualDir{
+		name:    name,
+		info:    info,
+		entries: entries,
+	}
+}
+
+// pathError creates an *os.PathError for the given operation on this directory
+func (vd *virtualDir) pathError(op string, err error) error {
+	return &os.PathError{
+		Op:   op,
+		Path: vd.name,
+		Err:  err,
+	}
 }
 
 func (vd *virtualDir) close() error {
-	//++ TODO: needs sync mutex?
 	if vd.closed {
-		return &os.PathError{
-			Op:   "close",
-			Path: vd.EmbeddedDir.Filename,
-			Err:  errors.New("already closed"),
-		}
+		return vd.pathError("close", os.ErrClosed)
 	}
 	vd.closed = true
 	return nil
@@ -171,134 +188,76 @@ func (vd *virtualDir) close() error {
 
 func (vd *virtualDir) stat() (os.FileInfo, error) {
 	if vd.closed {
-		return nil, &os.PathError{
-			Op:   "stat",
-			Path: vd.EmbeddedDir.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return nil, vd.pathError("stat", os.ErrClosed)
 	}
-	return (*embeddedDirInfo)(vd.EmbeddedDir), nil
+	return vd.info, nil
 }
 
-func (vd *virtualDir) readdir(n int) ([]os.FileInfo, error) {
-
-	if vd.closed {
-		return nil, &os.PathError{
-			Op:   "readdir",
-			Path: vd.EmbeddedDir.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
-	}
-
-	// Build up the array of our contents
-	var files []os.FileInfo
-
-	// Add the child directories
-	for _, child := range vd.ChildDirs {
-		child.Filename = filepath.Base(child.Filename)
-		files = append(files, (*embeddedDirInfo)(child))
-	}
-
-	// Add the child files
-	for _, child := range vd.ChildFiles {
-		child.Filename = filepath.Base(child.Filename)
-		files = append(files, (*embeddedFileInfo)(child))
-	}
+// next returns the next n entries of the directory listing, or all remaining entries when n <= 0.
+func (vd *virtualDir) next(n int) ([]os.FileInfo, error) {
+	remaining := vd.entries[vd.offset:]
 
-	// Sort it by filename (lexical order)
-	sort.Sort(SortByName(files))
-
-	// Return all contents if that's what is requested
+	// Return all remaining contents if that's what is requested
 	if n <= 0 {
-		vd.offset = 0
-		return files, nil
+		vd.offset += len(remaining)
+		return remaining, nil
 	}
 
-	// If user has requested past the end of our list
-	// return what we can and send an EOF
-	if vd.offset+n >= len(files) {
-		offset := vd.offset
-		vd.offset = 0
-		return files[offset:], io.EOF
+	// If there is nothing left, tell the user so
+	if len(remaining) == 0 {
+		return nil, io.EOF
 	}
 
-	offset := vd.offset
+	if n > len(remaining) {
+		n = len(remaining)
+	}
 	vd.offset += n
-	return files[offset : offset+n], nil
-
+	return remaining[:n], nil
 }
 
-func (vd *virtualDir) readdirnames(n int) ([]string, error) {
-
+func (vd *virtualDir) readdir(n int) ([]os.FileInfo, error) {
 	if vd.closed {
-		return nil, &os.PathError{
-			Op:   "readdir",
-			Path: vd.EmbeddedDir.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
-	}
-
-	// Build up the array of our contents
-	var files []string
-
-	// Add the child directories
-	for _, child := range vd.ChildDirs {
-		files = append(files, filepath.Base(child.Filename))
+		return nil, vd.pathError("readdir", os.ErrClosed)
 	}
+	entries, err := vd.next(n)
+	// copy, so the caller can't modify the listing
+	return append([]os.FileInfo{}, entries...), err
+}
 
-	// Add the child files
-	for _, child := range vd.ChildFiles {
-		files = append(files, filepath.Base(child.Filename))
+func (vd *virtualDir) readdirnames(n int) ([]string, error) {
+	if vd.closed {
+		return nil, vd.pathError("readdir", os.ErrClosed)
 	}
-
-	// Sort it by filename (lexical order)
-	sort.Strings(files)
-
-	// Return all contents if that's what is requested
-	if n <= 0 {
-		vd.offset = 0
-		return files, nil
+	entries, err := vd.next(n)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
 	}
-
-	// If user has requested past the end of our list
-	// return what we can and send an EOF
-	if vd.offset+n >= len(files) {
-		offset := vd.offset
-		vd.offset = 0
-		return files[offset:], io.EOF
-	}
-
-	offset := vd.offset
-	vd.offset += n
-	return files[offset : offset+n], nil
+	return names, err
 }
 
 func (vd *virtualDir) read(bts []byte) (int, error) {
 	if vd.closed {
-		return 0, &os.PathError{
-			Op:   "read",
-			Path: vd.EmbeddedDir.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return 0, vd.pathError("read", os.ErrClosed)
 	}
-	return 0, &os.PathError{
-		Op:   "read",
-		Path: vd.EmbeddedDir.Filename,
-		Err:  errors.New("is a directory"),
+	return 0, vd.pathError("read", syscall.EISDIR)
+}
+
+func (vd *virtualDir) readAt(bts []byte, offset int64) (int, error) {
+	if vd.closed {
+		return 0, vd.pathError("read", os.ErrClosed)
 	}
+	return 0, vd.pathError("read", syscall.EISDIR)
 }
 
 func (vd *virtualDir) seek(offset int64, whence int) (int64, error) {
 	if vd.closed {
-		return 0, &os.PathError{
-			Op:   "seek",
-			Path: vd.EmbeddedDir.Filename,
-			Err:  errors.New("bad file descriptor"),
-		}
+		return 0, vd.pathError("seek", os.ErrClosed)
 	}
-	return 0, &os.PathError{
-		Op:   "seek",
-		Path: vd.Filename,
-		Err:  errors.New("is a directory"),
+	// like os.File, seeking to the start of a directory rewinds the directory listing
+	if offset == 0 && whence == io.SeekStart {
+		vd.offset = 0
+		return 0, nil
 	}
+	return 0, vd.pathError("seek", syscall.EINVAL)
 }
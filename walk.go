@@ -11,18 +11,16 @@ import (
 // Visit http://golang.org/pkg/path/filepath/#Walk for more information
 func (b *Box) Walk(path string, walkFn filepath.WalkFunc) error {
 
-	pathFile, err := b.Open(path)
+	cleaned, err := cleanName("walk", path)
 	if err != nil {
 		return err
 	}
-	defer pathFile.Close()
-
-	pathInfo, err := pathFile.Stat()
+	pathInfo, err := b.stat(cleaned)
 	if err != nil {
 		return err
 	}
 
-	if b.IsAppended() || b.IsEmbedded() {
+	if b.IsAppended() || b.IsEmbedded() || b.isOverlay() {
 		return b.walk(path, pathInfo, walkFn)
 	}
 
@@ -61,13 +59,12 @@ func (b *Box) walk(path string, info os.FileInfo, walkFn filepath.WalkFunc) erro
 	for _, name := range names {
 
 		filename := filepath.ToSlash(filepath.Join(path, name))
-		fileObject, err := b.Open(filename)
+		cleaned, err := cleanName("stat", filename)
 		if err != nil {
 			return err
 		}
-		defer fileObject.Close()
-
-		fileInfo, err := fileObject.Stat()
+		// stat without opening, so encrypted files aren't decrypted
+		fileInfo, err := b.stat(cleaned)
 		if err != nil {
 			if err := walkFn(filename, fileInfo, err); err != nil && err != filepath.SkipDir {
 				return err
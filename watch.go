@@ -0,0 +1,172 @@
+package rice
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// EventOp describes a change to a file in a box.
+type EventOp int
+
+const (
+	EventCreate = EventOp(iota) // The file or directory was created.
+	EventModify                 // The content (size or modification time) of the file changed.
+	EventRemove                 // The file or directory was removed.
+)
+
+// String returns the name of the operation, e.g. "create"
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "create"
+	case EventModify:
+		return "modify"
+	case EventRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// Event is a change to a file or directory in a box
+type Event struct {
+	Name string  // path of the file within the box, e.g. "sub/file.txt"
+	Op   EventOp // what happened to the file
+}
+
+// defaultWatchInterval is used when Config.WatchInterval is not set
+const defaultWatchInterval = time.Second
+
+// Watch calls fn with the changes to the files in the box, until ctx is
+// done. Boxes on disk are checked for changes every Config.WatchInterval,
+// fn is called with the events of one check, sorted by name. Watch returns
+// ctx.Err() when ctx is done.
+//
+// Embedded and appended boxes never change: fn is never called, Watch only
+// waits for ctx to be done. This allows hot-reloading from a box in
+// development, with the same code that runs in a release build.
+func (b *Box) Watch(ctx context.Context, fn func([]Event)) error {
+	if !b.isLive() {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	interval := b.watchInterval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	files, err := b.scan()
+	if err != nil {
+		return err
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		// a box directory that disappears is reported as the removal of its content
+		current, _ := b.scan()
+		if events := diffScans(files, current); len(events) > 0 {
+			fn(events)
+		}
+		files = current
+	}
+}
+
+// scannedFile is the state of a file in a box on disk, when it was scanned
+type scannedFile struct {
+	dir     bool
+	modTime time.Time
+	size    int64
+}
+
+// scan returns the state of all files and directories in the box on disk,
+// keyed by their path within the box.
+func (b *Box) scan() (map[string]scannedFile, error) {
+	if b.isOverlay() {
+		return b.scanOverlay()
+	}
+	// filepath.Walk doesn't descend into a box directory that is a symbolic link
+	root, err := filepath.EvalSymlinks(b.absolutePath)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, &os.PathError{Op: "watch", Path: root, Err: syscall.ENOTDIR}
+	}
+	files := make(map[string]scannedFile)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// files can be removed while the box is scanned
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		files[filepath.ToSlash(rel)] = scannedFile{
+			dir:     info.IsDir(),
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+		return nil
+	})
+	return files, err
+}
+
+// diffScans returns the events that turn the old scan into the new one, sorted by name
+func diffScans(old, new map[string]scannedFile) []Event {
+	var events []Event
+	for name, file := range new {
+		oldFile, ok := old[name]
+		switch {
+		case !ok:
+			events = append(events, Event{Name: name, Op: EventCreate})
+		case oldFile.dir != file.dir:
+			// replaced, e.g. a file by a directory
+			events = append(events, Event{Name: name, Op: EventRemove}, Event{Name: name, Op: EventCreate})
+		case !file.dir && (!oldFile.modTime.Equal(file.modTime) || oldFile.size != file.size):
+			events = append(events, Event{Name: name, Op: EventModify})
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			events = append(events, Event{Name: name, Op: EventRemove})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Name < events[j].Name })
+	return events
+}
+
+// scanOverlay returns the state of all files and directories in an overlay
+// box, as visible through its layers.
+func (b *Box) scanOverlay() (map[string]scannedFile, error) {
+	files := make(map[string]scannedFile)
+	err := b.Walk("", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			// files can be removed while the box is scanned
+			return nil
+		}
+		if name == "" {
+			return nil
+		}
+		files[name] = scannedFile{
+			dir:     info.IsDir(),
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+		return nil
+	})
+	return files, err
+}
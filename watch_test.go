@@ -0,0 +1,106 @@
+package rice
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	box := newFSTestBox(t)
+	box.watchInterval = 10 * time.Millisecond
+	fullPath := func(name string) string {
+		return filepath.Join(box.absolutePath, filepath.FromSlash(name))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []Event)
+	done := make(chan error)
+	go func() {
+		done <- box.Watch(ctx, func(events []Event) { changes <- events })
+	}()
+	// let Watch scan the box before changing it
+	time.Sleep(50 * time.Millisecond)
+
+	// collect events until the expected number arrived
+	expect := func(expected ...Event) {
+		t.Helper()
+		var events []Event
+		timeout := time.After(5 * time.Second)
+		for len(events) < len(expected) {
+			select {
+			case batch := <-changes:
+				events = append(events, batch...)
+			case <-timeout:
+				t.Fatalf("timeout, got events %v, expected %v", events, expected)
+			}
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("got events %v, expected %v", events, expected)
+		}
+	}
+
+	// the file is moved into the box, so a scan never sees it half written
+	newFile := filepath.Join(t.TempDir(), "new.txt")
+	if err := ioutil.WriteFile(newFile, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(newFile, fullPath("new.txt")); err != nil {
+		t.Fatal(err)
+	}
+	expect(Event{"new.txt", EventCreate})
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(fullPath("file.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	expect(Event{"file.txt", EventModify})
+
+	if err := os.RemoveAll(fullPath("sub/deeper")); err != nil {
+		t.Fatal(err)
+	}
+	expect(
+		Event{"sub/deeper", EventRemove},
+		Event{"sub/deeper/c.txt", EventRemove},
+		Event{"sub/deeper/nested", EventRemove},
+		Event{"sub/deeper/nested/d.txt", EventRemove},
+	)
+
+	if err := os.Remove(fullPath("empty.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(fullPath("empty.txt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	expect(Event{"empty.txt", EventRemove}, Event{"empty.txt", EventCreate})
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWatchNeverChanges(t *testing.T) {
+	for _, box := range []*Box{newEmbeddedTestBox(), newAppendedTestBox(t)} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err := box.Watch(ctx, func(events []Event) {
+			t.Errorf("unexpected events %v", events)
+		})
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	}
+}
+
+func TestWatchMissingBox(t *testing.T) {
+	box := &Box{name: "missing", absolutePath: filepath.Join(os.TempDir(), "rice-missing-box")}
+	if err := box.Watch(context.Background(), func([]Event) {}); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+}